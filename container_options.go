@@ -0,0 +1,106 @@
+package logstash
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// Container labels overriding route options. Labels take precedence over the
+// route options, which take precedence over the defaults:
+//
+//	logstash.enable=false      ship nothing from the container
+//	logstash.format=text       json or text, like the format route option
+//	logstash.parsers=klog      like the parsers route option; none disables
+//	logstash.fields.<name>=v   like fields.<name>, without ${VAR} interpolation
+//	logstash.min_level=warn    like the min_level route option
+const (
+	enableLabel      = "logstash.enable"
+	formatLabel      = "logstash.format"
+	parsersLabel     = "logstash.parsers"
+	fieldLabelPrefix = "logstash.fields."
+)
+
+const (
+	// formatJSON ships the fields of JSON lines, and of text lines ending
+	// in a JSON object unless mixed_json=false
+	formatJSON = "json"
+	// formatText ships every line as a plain message
+	formatText = "text"
+)
+
+// errTextFormat stands for the decoding error of messages that are not
+// decoded as JSON because of format=text.
+var errTextFormat = errors.New("text format")
+
+// containerOptions are the route options in effect for one container.
+type containerOptions struct {
+	enabled     bool
+	format      string
+	parsers     []parserFn
+	fields      *staticFields
+	minSeverity int
+}
+
+// optionsFor returns the options of container: the route options with any
+// logstash.* labels applied, cached by container ID.
+func (a *LogstashAdapter) optionsFor(container *docker.Container) *containerOptions {
+	if options, ok := a.containerOptions[container.ID]; ok {
+		return options
+	}
+
+	options := &containerOptions{
+		enabled:     true,
+		format:      a.format,
+		parsers:     a.parsers,
+		fields:      a.staticFields,
+		minSeverity: a.minSeverity,
+	}
+	labels := containerLabels(container)
+
+	if value, ok := labels[enableLabel]; ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Println("logstash: invalid", enableLabel, "on container", container.ID+":", value)
+		} else {
+			options.enabled = enabled
+		}
+	}
+	if format, ok := labels[formatLabel]; ok {
+		if validFormat(format) {
+			options.format = format
+		} else {
+			log.Println("logstash: invalid", formatLabel, "on container", container.ID+":", format)
+		}
+	}
+	if parsers, ok := labels[parsersLabel]; ok {
+		options.parsers = nil
+		if parsers != "none" {
+			options.parsers = lookupParsers(parsers)
+		}
+	}
+
+	if minLevel, ok := labels[minLevelLabel]; ok {
+		options.minSeverity = a.levels.minSeverity(minLevel)
+	}
+
+	fields := make(map[string]string)
+	for label, value := range labels {
+		if name := strings.TrimPrefix(label, fieldLabelPrefix); name != label && name != "" {
+			fields[name] = value
+		}
+	}
+	if len(fields) > 0 {
+		options.fields = options.fields.withOverrides(fields)
+	}
+
+	a.containerOptions[container.ID] = options
+	return options
+}
+
+func validFormat(format string) bool {
+	return format == formatJSON || format == formatText
+}
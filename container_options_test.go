@@ -0,0 +1,107 @@
+package logstash
+
+import (
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamContainerOptOut(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	skipped := makeDummyContainer("skipped")
+	skipped.Config.Labels = map[string]string{"logstash.enable": "false"}
+	shipped := makeDummyContainer("shipped")
+
+	go func() {
+		for _, container := range []*router.Message{
+			{Container: &skipped, Data: "secret"},
+			{Container: &shipped, Data: "public"},
+		} {
+			logstream <- container
+		}
+		close(logstream)
+	}()
+
+	adapter.Stream(logstream)
+	assert.Equal(1, len(*results))
+	data := parseResult(assert, (*results)[0])
+	assert.Equal("public", data["message"])
+}
+
+func TestStreamContainerOptionLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{
+		"fields.cluster":    "blue",
+		"fields.datacenter": "eu-west-1",
+		"tags":              "docker",
+	}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	container.Config.Labels = map[string]string{
+		"logstash.format":         "text",
+		"logstash.parsers":        "none",
+		"logstash.fields.team":    "payments",
+		"logstash.fields.cluster": "${HOME}",
+	}
+	lines := []string{
+		`{"message": "json"}`,
+		`12:00:00.000[INFO ][uuid][main][Logger]: text`,
+	}
+
+	go pump(logstream, &container, [][]string{{lines[0]}, {lines[1]}})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+
+	for i, result := range *results {
+		data := parseResult(assert, result)
+		assert.Equal(lines[i], data["message"], "neither decoded nor parsed")
+		assert.Nil(data["javaLog"])
+		assert.Equal("payments", data["team"])
+		assert.Equal("${HOME}", data["cluster"], "label values are not interpolated")
+		assert.Equal("eu-west-1", data["datacenter"])
+		assert.Equal([]interface{}{"docker"}, data["tags"])
+	}
+}
+
+func TestOptionsFor(t *testing.T) {
+	assert := assert.New(t)
+
+	var r router.Route
+	r.Options = map[string]string{"format": "text"}
+	adapter := newLogstashAdapter(&r, nil)
+
+	plain := makeDummyContainer("plain")
+	options := adapter.optionsFor(&plain)
+	assert.True(options.enabled)
+	assert.Equal(formatText, options.format)
+	assert.Equal(len(adapter.parsers), len(options.parsers))
+	assert.Nil(options.fields)
+	assert.True(options == adapter.optionsFor(&plain), "options are cached per container")
+
+	invalid := makeDummyContainer("invalid")
+	invalid.Config.Labels = map[string]string{
+		"logstash.enable":  "maybe",
+		"logstash.format":  "xml",
+		"logstash.parsers": "klog",
+	}
+	options = adapter.optionsFor(&invalid)
+	assert.True(options.enabled)
+	assert.Equal(formatText, options.format)
+	assert.Equal(1, len(options.parsers))
+
+	adapter.removeContainer("invalid")
+	adapter.forgetStoppedContainers()
+	assert.NotContains(adapter.containerOptions, "invalid")
+}
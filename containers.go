@@ -0,0 +1,102 @@
+package logstash
+
+import (
+	"time"
+
+	"github.com/anashaka/logspout-logstash/multiline"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// containerEventSource is the part of the Docker client used to learn about
+// containers that stopped.
+type containerEventSource interface {
+	AddEventListener(listener chan<- *docker.APIEvents) error
+}
+
+// watchContainers subscribes to the Docker events of source, so that the
+// buffers of a container are flushed and dropped as soon as it dies or is
+// removed instead of lingering until they expire.
+func (a *LogstashAdapter) watchContainers(source containerEventSource) error {
+	events := make(chan *docker.APIEvents, 64)
+	if err := source.AddEventListener(events); err != nil {
+		return err
+	}
+	a.containerEvents = events
+	return nil
+}
+
+// handleContainerEvent returns the events flushed from the buffers of the
+// container a die or destroy event is about. A container that starts again
+// keeps its ID, so its state is no longer forgotten once it restarts.
+func (a *LogstashAdapter) handleContainerEvent(event *docker.APIEvents) []*multiline.Event {
+	if event.Type != "" && event.Type != "container" {
+		return nil
+	}
+
+	action := event.Action
+	if action == "" {
+		// API versions before 1.22
+		action = event.Status
+	}
+	id := event.Actor.ID
+	if id == "" {
+		id = event.ID
+	}
+
+	switch action {
+	case "die", "destroy":
+		return a.removeContainer(id)
+	case "start", "restart":
+		a.removed.remove(id)
+	}
+	return nil
+}
+
+// removeContainer flushes and forgets everything buffered for the container.
+func (a *LogstashAdapter) removeContainer(id string) []*multiline.Event {
+	var messages []*multiline.Event
+
+	if a.partials != nil {
+		for _, msg := range a.partials.flushContainer(id) {
+			messages = append(messages, a.bufferLine(msg)...)
+		}
+	}
+
+	for source := range a.streams[id] {
+		key := id + source
+		buf := a.cache[key]
+		lines, bytes := buf.PendingSize(), buf.PendingBytes()
+		if msg := buf.Flush(); msg != nil {
+			messages = append(messages, msg)
+		}
+		a.trackPending(-lines, -bytes)
+		a.dropBuffer(key, id, source)
+	}
+
+	// late lines of the container are expected for a cache TTL
+	a.removed.set(id, time.Now().Add(a.cacheTTL))
+	// the state of the container is still needed to serialize and order
+	// the flushed events
+	a.stopped = append(a.stopped, id)
+
+	return messages
+}
+
+// forgetStoppedContainers drops the state of the containers removed since the
+// last call, once their last events have been sent. The state of a container
+// that still has lines buffered is dropped when the last buffer goes.
+func (a *LogstashAdapter) forgetStoppedContainers() {
+	for _, id := range a.stopped {
+		if a.streams[id] != nil {
+			continue
+		}
+		delete(a.sequences, id)
+		delete(a.multilineConfigs, id)
+		delete(a.containerOptions, id)
+		a.threshold.forget(id)
+		if a.metadata != nil {
+			a.metadata.forget(id)
+		}
+	}
+	a.stopped = nil
+}
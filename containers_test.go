@@ -0,0 +1,155 @@
+package logstash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEventSource struct {
+	listener chan<- *docker.APIEvents
+}
+
+func (f *fakeEventSource) AddEventListener(listener chan<- *docker.APIEvents) error {
+	f.listener = listener
+	return nil
+}
+
+func TestStreamContainerDies(t *testing.T) {
+	assert := assert.New(t)
+
+	chanWriter, written := makeChanWriter()
+	var r router.Route
+	r.Options = map[string]string{"cache_ttl": "1h"}
+	adapter := newLogstashAdapter(&r, chanWriter)
+	events := new(fakeEventSource)
+	assert.Nil(adapter.watchContainers(events))
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	done := make(chan bool)
+	go func() {
+		adapter.Stream(logstream)
+		close(done)
+	}()
+
+	msg := makeDummyMessage(&container, "last words")
+	logstream <- &msg
+	events.listener <- &docker.APIEvents{Type: "container", Action: "die", Actor: docker.APIActor{ID: "anid"}}
+
+	select {
+	case result := <-written:
+		data := parseResult(assert, result)
+		assert.Equal("last words", data["message"])
+	case <-time.After(time.Second):
+		assert.Fail("buffers of a dead container must be flushed")
+	}
+
+	close(logstream)
+	<-done
+	assert.Empty(adapter.cache)
+}
+
+func TestHandleContainerEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	var r router.Route
+	r.Options = map[string]string{"ordering": "time"}
+	adapter := newLogstashAdapter(&r, nil)
+	stopped := makeDummyContainer("stopped")
+	running := makeDummyContainer("running")
+
+	for _, msg := range []router.Message{
+		{Container: &stopped, Source: "stdout", Data: "out"},
+		{Container: &stopped, Source: "stderr", Data: "err"},
+		{Container: &running, Source: "stdout", Data: "still here"},
+	} {
+		msg := msg
+		adapter.bufferMessage(&msg)
+	}
+
+	// only die and destroy events of containers matter
+	assert.Empty(adapter.handleContainerEvent(&docker.APIEvents{Type: "container", Action: "start", Actor: docker.APIActor{ID: "stopped"}}))
+	assert.Empty(adapter.handleContainerEvent(&docker.APIEvents{Type: "network", Action: "destroy", Actor: docker.APIActor{ID: "stopped"}}))
+	assert.Equal(3, len(adapter.cache))
+
+	events := adapter.handleContainerEvent(&docker.APIEvents{Status: "destroy", ID: "stopped"})
+	assert.Equal(2, len(events))
+	assert.Equal(1, len(adapter.cache))
+	assert.Nil(adapter.streams["stopped"])
+	assert.Equal(int64(1), adapter.pendingLines)
+
+	ready := adapter.orderEvents(events, false)
+	assert.Equal(2, len(ready))
+	assert.Equal(uint64(2), adapter.sequences["stopped"])
+	adapter.forgetStoppedContainers()
+	assert.NotContains(adapter.sequences, "stopped")
+	assert.NotContains(adapter.multilineConfigs, "stopped")
+	assert.NotContains(adapter.containerOptions, "stopped")
+	assert.Contains(adapter.multilineConfigs, "running")
+}
+
+func TestLinesAfterContainerDies(t *testing.T) {
+	assert := assert.New(t)
+
+	var r router.Route
+	r.Options = map[string]string{"ordering": "time", "include_labels": "*"}
+	adapter := newLogstashAdapter(&r, nil)
+	container := makeDummyContainer("anid")
+
+	adapter.handleContainerEvent(&docker.APIEvents{Type: "container", Action: "die", Actor: docker.APIActor{ID: "anid"}})
+	adapter.forgetStoppedContainers()
+
+	msg := makeDummyMessage(&container, "late line")
+	assert.Empty(adapter.bufferMessage(&msg))
+	adapter.forgetStoppedContainers()
+	assert.Contains(adapter.multilineConfigs, "anid", "state is kept while a line is buffered")
+
+	events := adapter.expireCache(time.Now().Add(adapter.cacheTTL + time.Second))
+	assert.Equal(1, len(events))
+	for _, event := range adapter.orderEvents(events, false) {
+		_, err := adapter.serialize(event)
+		assert.Nil(err)
+	}
+	adapter.forgetStoppedContainers()
+	assert.NotContains(adapter.multilineConfigs, "anid")
+	assert.NotContains(adapter.containerOptions, "anid")
+	assert.NotContains(adapter.sequences, "anid")
+	assert.NotContains(adapter.metadata.containers, "anid")
+}
+
+func TestContainerRestarts(t *testing.T) {
+	assert := assert.New(t)
+
+	var r router.Route
+	r.Options = map[string]string{"ordering": "time"}
+	adapter := newLogstashAdapter(&r, nil)
+	container := makeDummyContainer("anid")
+
+	adapter.handleContainerEvent(&docker.APIEvents{Type: "container", Action: "die", Actor: docker.APIActor{ID: "anid"}})
+	adapter.handleContainerEvent(&docker.APIEvents{Type: "container", Action: "start", Actor: docker.APIActor{ID: "anid"}})
+	adapter.forgetStoppedContainers()
+	assert.False(adapter.removed.has("anid"))
+
+	var sequences []uint64
+	for i, line := range []string{"one", "two", "three"} {
+		msg := makeDummyMessage(&container, line)
+		adapter.bufferMessage(&msg)
+		// each line expires before the next one, dropping its buffer
+		events := adapter.expireCache(time.Now().Add(time.Duration(i+1) * (adapter.cacheTTL + time.Second)))
+		for _, event := range adapter.orderEvents(events, true) {
+			sequences = append(sequences, event.Sequence)
+		}
+		adapter.forgetStoppedContainers()
+	}
+	assert.Equal([]uint64{1, 2, 3}, sequences, "the state of a restarted container is kept")
+
+	adapter.handleContainerEvent(&docker.APIEvents{Type: "container", Action: "die", Actor: docker.APIActor{ID: "other"}})
+	assert.True(adapter.removed.has("other"))
+	adapter.expireCache(time.Now().Add(adapter.cacheTTL + time.Second))
+	assert.False(adapter.removed.has("other"), "removed containers are forgotten after a cache TTL")
+}
@@ -0,0 +1,111 @@
+package logstash
+
+import (
+	"strconv"
+	"strings"
+)
+
+const defaultEmbeddedJSONDepth = 3
+
+// embeddedJSONDecoder replaces string fields holding escaped JSON with the
+// decoded object or array. Strings that fail to decode are kept as they are.
+type embeddedJSONDecoder struct {
+	auto     bool
+	paths    [][]string
+	maxDepth int
+}
+
+// newEmbeddedJSONDecoder builds a decoder from the route options, or returns
+// nil when decoding is not enabled:
+//
+//	decode_json_fields    dotted field paths, or "auto" to check every string
+//	decode_json_max_depth how many levels of JSON-in-JSON are decoded
+func newEmbeddedJSONDecoder(options map[string]string) *embeddedJSONDecoder {
+	fields := splitList(options["decode_json_fields"])
+	if len(fields) == 0 {
+		return nil
+	}
+
+	maxDepth, err := strconv.Atoi(options["decode_json_max_depth"])
+	if err != nil || maxDepth <= 0 {
+		maxDepth = defaultEmbeddedJSONDepth
+	}
+
+	decoder := embeddedJSONDecoder{maxDepth: maxDepth}
+	for _, field := range fields {
+		if field == "auto" {
+			decoder.auto = true
+		} else {
+			decoder.paths = append(decoder.paths, strings.Split(field, "."))
+		}
+	}
+	return &decoder
+}
+
+// decode rewrites the configured fields of doc in place.
+func (d *embeddedJSONDecoder) decode(doc *jsonObject) {
+	if d.auto {
+		d.decodeValue(doc, d.maxDepth, true)
+		return
+	}
+
+	for _, path := range d.paths {
+		parent := doc
+		for _, key := range path[:len(path)-1] {
+			value, _ := parent.Get(key)
+			child, ok := value.(*jsonObject)
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = child
+		}
+		if parent == nil {
+			continue
+		}
+
+		leaf := path[len(path)-1]
+		if value, ok := parent.Get(leaf); ok {
+			parent.Set(leaf, d.decodeValue(value, d.maxDepth, false))
+		}
+	}
+}
+
+// decodeValue decodes value if it is a string holding a JSON object or array,
+// then keeps decoding strings nested in the result until depth runs out. With
+// walk set, strings inside objects and arrays that were not themselves
+// decoded are visited as well.
+func (d *embeddedJSONDecoder) decodeValue(value interface{}, depth int, walk bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		if depth <= 0 {
+			return v
+		}
+		trimmed := strings.TrimSpace(v)
+		if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			return v
+		}
+		decoded, err := decodeJSON([]byte(trimmed))
+		if err != nil {
+			return v
+		}
+		return d.decodeValue(decoded, depth-1, true)
+	case *jsonObject:
+		if walk {
+			for _, key := range v.Keys() {
+				child, _ := v.Get(key)
+				v.Set(key, d.decodeValue(child, depth, walk))
+			}
+		}
+		return v
+	case []interface{}:
+		if walk {
+			for i, child := range v {
+				v[i] = d.decodeValue(child, depth, walk)
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
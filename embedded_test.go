@@ -0,0 +1,60 @@
+package logstash
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamEmbeddedJSONPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"decode_json_fields": "payload,request.body"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{{
+		`{"message":"saved","payload":"{\"id\":7,\"tags\":[\"a\"]}","request":{"body":"[1,2]"},"other":"{\"x\":1}"}`,
+	}})
+
+	adapter.Stream(logstream)
+	data := parseResult(assert, (*results)[0])
+
+	assert.Equal("saved", data["message"])
+	assert.Equal(map[string]interface{}{"id": float64(7), "tags": []interface{}{"a"}}, data["payload"])
+	assert.Equal([]interface{}{float64(1), float64(2)}, data["request"].(map[string]interface{})["body"])
+	assert.Equal(`{"x":1}`, data["other"], "fields not listed are left alone")
+}
+
+func TestEmbeddedJSONAutoDepth(t *testing.T) {
+	decoder := newEmbeddedJSONDecoder(map[string]string{
+		"decode_json_fields":    "auto",
+		"decode_json_max_depth": "2",
+	})
+
+	doc := mustDecodeJSONObject(t, `{"a":"{\"b\":\"{\\\"c\\\":\\\"{}\\\"}\"}","n":{"inner":"[true]"}}`)
+	decoder.decode(doc)
+
+	encoded, _ := json.Marshal(doc)
+	assert.Equal(t, `{"a":{"b":{"c":"{}"}},"n":{"inner":[true]}}`, string(encoded), "decoding stops at the depth limit")
+}
+
+func TestEmbeddedJSONKeepsInvalid(t *testing.T) {
+	decoder := newEmbeddedJSONDecoder(map[string]string{"decode_json_fields": "message"})
+
+	doc := mustDecodeJSONObject(t, `{"message":"{not json"}`)
+	decoder.decode(doc)
+
+	message, _ := doc.Get("message")
+	assert.Equal(t, `{not json`, message)
+}
+
+func TestEmbeddedJSONDisabled(t *testing.T) {
+	assert.Nil(t, newEmbeddedJSONDecoder(map[string]string{}))
+}
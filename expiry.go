@@ -0,0 +1,107 @@
+package logstash
+
+import (
+	"container/heap"
+	"time"
+)
+
+// deadlineQueue is a min-heap of keys ordered by deadline, so that the next
+// buffer to expire is found without scanning the whole cache.
+type deadlineQueue struct {
+	entries []*deadlineEntry
+	byKey   map[string]*deadlineEntry
+}
+
+type deadlineEntry struct {
+	key      string
+	deadline time.Time
+	index    int
+}
+
+func newDeadlineQueue() *deadlineQueue {
+	return &deadlineQueue{byKey: make(map[string]*deadlineEntry)}
+}
+
+// set schedules key at deadline, replacing any earlier deadline of key.
+func (q *deadlineQueue) set(key string, deadline time.Time) {
+	if entry, ok := q.byKey[key]; ok {
+		entry.deadline = deadline
+		heap.Fix(q, entry.index)
+		return
+	}
+	entry := &deadlineEntry{key: key, deadline: deadline}
+	q.byKey[key] = entry
+	heap.Push(q, entry)
+}
+
+// remove unschedules key.
+func (q *deadlineQueue) remove(key string) {
+	if entry, ok := q.byKey[key]; ok {
+		heap.Remove(q, entry.index)
+		delete(q.byKey, key)
+	}
+}
+
+// has reports whether key is scheduled.
+func (q *deadlineQueue) has(key string) bool {
+	_, ok := q.byKey[key]
+	return ok
+}
+
+// next returns the earliest deadline, if any key is scheduled.
+func (q *deadlineQueue) next() (time.Time, bool) {
+	if len(q.entries) == 0 {
+		return time.Time{}, false
+	}
+	return q.entries[0].deadline, true
+}
+
+// first returns the key with the earliest deadline, if any key is scheduled.
+func (q *deadlineQueue) first() (string, bool) {
+	if len(q.entries) == 0 {
+		return "", false
+	}
+	return q.entries[0].key, true
+}
+
+// popExpired unschedules and returns the keys whose deadline is not after t,
+// earliest first.
+func (q *deadlineQueue) popExpired(t time.Time) []string {
+	var keys []string
+	for len(q.entries) > 0 && !q.entries[0].deadline.After(t) {
+		entry := heap.Pop(q).(*deadlineEntry)
+		delete(q.byKey, entry.key)
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
+
+// heap.Interface
+
+func (q *deadlineQueue) Len() int {
+	return len(q.entries)
+}
+
+func (q *deadlineQueue) Less(i, j int) bool {
+	return q.entries[i].deadline.Before(q.entries[j].deadline)
+}
+
+func (q *deadlineQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].index = i
+	q.entries[j].index = j
+}
+
+func (q *deadlineQueue) Push(x interface{}) {
+	entry := x.(*deadlineEntry)
+	entry.index = len(q.entries)
+	q.entries = append(q.entries, entry)
+}
+
+func (q *deadlineQueue) Pop() interface{} {
+	last := len(q.entries) - 1
+	entry := q.entries[last]
+	q.entries[last] = nil
+	q.entries = q.entries[:last]
+	return entry
+}
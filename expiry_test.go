@@ -0,0 +1,65 @@
+package logstash
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlineQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	t0 := time.Now()
+	q := newDeadlineQueue()
+	_, ok := q.next()
+	assert.False(ok)
+
+	q.set("c", t0.Add(3*time.Second))
+	q.set("a", t0.Add(1*time.Second))
+	q.set("b", t0.Add(2*time.Second))
+	q.set("d", t0.Add(4*time.Second))
+
+	next, ok := q.next()
+	assert.True(ok)
+	assert.Equal(t0.Add(time.Second), next)
+
+	// rescheduling and removing keep the heap ordered
+	q.set("a", t0.Add(5*time.Second))
+	q.remove("c")
+	q.remove("missing")
+
+	assert.Nil(q.popExpired(t0))
+	assert.Equal([]string{"b", "d"}, q.popExpired(t0.Add(4*time.Second)))
+	assert.Equal([]string{"a"}, q.popExpired(t0.Add(time.Minute)))
+	assert.Empty(q.byKey)
+}
+
+func BenchmarkExpireCache(b *testing.B) {
+	var r router.Route
+	r.Options = map[string]string{"cache_ttl": "1h"}
+	adapter := newLogstashAdapter(&r, nil)
+
+	containers := make([]*router.Message, 5000)
+	for i := range containers {
+		container := makeDummyContainer(fmt.Sprintf("container-%d", i))
+		msg := makeDummyMessage(&container, "pending line")
+		containers[i] = &msg
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for _, msg := range containers {
+			adapter.bufferMessage(msg)
+		}
+		b.StartTimer()
+
+		expired := adapter.expireCache(time.Now().Add(adapter.cacheTTL + time.Second))
+		if len(expired) != len(containers) || len(adapter.cache) != 0 {
+			b.Fatalf("expired %d of %d buffers, %d left", len(expired), len(containers), len(adapter.cache))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package logstash
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+const fieldOptionPrefix = "fields."
+
+// staticFields are the fields.<name>=value and tags=a,b route options,
+// stamped on every event. ${VAR} references in values are replaced with
+// environment variables of the logspout process. field_conflict decides
+// what happens to keys the document already has:
+//
+//	keep      the document's value wins (default)
+//	overwrite the static value wins
+//
+// Static tags are always added to a tags array already in the document.
+type staticFields struct {
+	names     []string
+	values    map[string]string
+	tags      []string
+	overwrite bool
+}
+
+// newStaticFields returns nil when the route has no fields or tags.
+func newStaticFields(options map[string]string) *staticFields {
+	fields := &staticFields{values: make(map[string]string)}
+	for option, value := range options {
+		if !strings.HasPrefix(option, fieldOptionPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(option, fieldOptionPrefix)
+		if name == "" {
+			continue
+		}
+		fields.names = append(fields.names, name)
+		fields.values[name] = os.ExpandEnv(value)
+	}
+	sort.Strings(fields.names)
+	fields.tags = splitList(os.ExpandEnv(options["tags"]))
+
+	if len(fields.names) == 0 && len(fields.tags) == 0 {
+		return nil
+	}
+
+	switch policy := options["field_conflict"]; policy {
+	case "", "keep":
+	case "overwrite":
+		fields.overwrite = true
+	default:
+		log.Println("logstash: unknown field_conflict policy:", policy)
+	}
+	return fields
+}
+
+// withOverrides returns a copy of f with the fields set to values taken
+// literally. It is safe to call on nil.
+func (f *staticFields) withOverrides(values map[string]string) *staticFields {
+	merged := &staticFields{values: make(map[string]string)}
+	if f != nil {
+		merged.tags = f.tags
+		merged.overwrite = f.overwrite
+		for name, value := range f.values {
+			merged.values[name] = value
+		}
+	}
+	for name, value := range values {
+		merged.values[name] = value
+	}
+	for name := range merged.values {
+		merged.names = append(merged.names, name)
+	}
+	sort.Strings(merged.names)
+	return merged
+}
+
+// apply adds the fields and tags to obj.
+func (f *staticFields) apply(obj *jsonObject) {
+	for _, name := range f.names {
+		if _, ok := obj.Get(name); ok && !f.overwrite {
+			continue
+		}
+		obj.Set(name, f.values[name])
+	}
+
+	if len(f.tags) == 0 {
+		return
+	}
+	existing, ok := obj.Get("tags")
+	if !ok {
+		obj.Set("tags", f.tags)
+		return
+	}
+	if tags, isArray := existing.([]interface{}); isArray {
+		obj.Set("tags", mergeTags(tags, f.tags))
+	} else if f.overwrite {
+		obj.Set("tags", f.tags)
+	}
+}
+
+func mergeTags(tags []interface{}, extra []string) []interface{} {
+	merged := append([]interface{}{}, tags...)
+	for _, tag := range extra {
+		found := false
+		for _, existing := range tags {
+			if existing == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// decorate adds the optional sections and the static fields to obj.
+func decorate(obj *jsonObject, extra []extraField, fields *staticFields) {
+	for _, field := range extra {
+		obj.Set(field.key, field.value)
+	}
+	if fields != nil {
+		fields.apply(obj)
+	}
+}
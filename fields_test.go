@@ -0,0 +1,69 @@
+package logstash
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamStaticFields(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("LOGSTASH_TEST_CLUSTER", "blue")
+	defer os.Unsetenv("LOGSTASH_TEST_CLUSTER")
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{
+		"fields.datacenter": "eu-west-1",
+		"fields.cluster":    "${LOGSTASH_TEST_CLUSTER}-prod",
+		"fields.stream":     "ignored",
+		"tags":              "docker, ${LOGSTASH_TEST_CLUSTER}",
+	}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{
+		{"plain"},
+		{`{"message": "json", "datacenter": "app", "tags": ["app", "docker"]}`},
+	})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+
+	data := parseResult(assert, (*results)[0])
+	assert.Equal("eu-west-1", data["datacenter"])
+	assert.Equal("blue-prod", data["cluster"])
+	assert.Equal("FOOOOO", data["stream"], "generated fields are kept")
+	assert.Equal([]interface{}{"docker", "blue"}, data["tags"])
+
+	data = parseResult(assert, (*results)[1])
+	assert.Equal("app", data["datacenter"], "application fields are kept")
+	assert.Equal("blue-prod", data["cluster"])
+	assert.Equal([]interface{}{"app", "docker", "blue"}, data["tags"])
+}
+
+func TestStaticFieldsOverwrite(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(newStaticFields(map[string]string{"pattern": "x"}))
+
+	fields := newStaticFields(map[string]string{
+		"fields.datacenter": "eu-west-1",
+		"tags":              "docker",
+		"field_conflict":    "overwrite",
+	})
+	obj := mustDecodeJSONObject(t, `{"datacenter": "app", "tags": "app"}`)
+	fields.apply(obj)
+	assert.Equal("eu-west-1", mustGet(obj, "datacenter"))
+	assert.Equal([]string{"docker"}, mustGet(obj, "tags"))
+}
+
+func mustGet(obj *jsonObject, key string) interface{} {
+	value, _ := obj.Get(key)
+	return value
+}
@@ -0,0 +1,174 @@
+package logstash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	filterOptionPrefix = "filter."
+	labelFieldPrefix   = "label."
+	streamField        = "stream"
+)
+
+// errFiltered is returned by serialize for events dropped by the filters.
+var errFiltered = errors.New("filtered")
+
+// filterRule drops or keeps the events whose field matches pattern.
+type filterRule struct {
+	name    string
+	drop    bool
+	field   string
+	pattern *regexp.Regexp
+	hits    metrics.Counter
+}
+
+// eventFilter is the ordered list of filter.<n> route options, each of the
+// form "drop|keep <field> <regexp>", e.g.
+//
+//	filter.1=drop message ^GET /health
+//	filter.2=keep javaLog.level ^(WARN|ERROR)$
+//	filter.3=drop label.com.example.tier ^debug$
+//
+// <field> is a dotted path into the event document, label.<name> for a
+// container label, or stream for the stream the line was written to, which
+// only plain lines carry in their document. Rules are tried in the order of n
+// and the first rule matching an event decides; events no rule matches are
+// kept, unless filter_default=drop. Every rule counts its hits in a
+// <route>_filter_<n>_hits counter.
+type eventFilter struct {
+	rules       []*filterRule
+	dropDefault bool
+}
+
+// newEventFilter returns nil when the route has no filter rules.
+func newEventFilter(routeID string, options map[string]string) *eventFilter {
+	var indexes []int
+	for option := range options {
+		if !strings.HasPrefix(option, filterOptionPrefix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(option, filterOptionPrefix))
+		if err != nil {
+			log.Println("logstash: invalid filter option:", option)
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	filter := &eventFilter{dropDefault: options["filter_default"] == "drop"}
+	for _, index := range indexes {
+		name := strconv.Itoa(index)
+		rule, err := parseFilterRule(name, options[filterOptionPrefix+name])
+		if err != nil {
+			log.Println("logstash: invalid filter", name+":", err)
+			continue
+		}
+		rule.hits = metrics.NewCounter()
+		metrics.Register(routeID+"_filter_"+name+"_hits", rule.hits)
+		filter.rules = append(filter.rules, rule)
+	}
+
+	if len(filter.rules) == 0 && !filter.dropDefault {
+		return nil
+	}
+	return filter
+}
+
+func parseFilterRule(name, value string) (*filterRule, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(parts) != 3 {
+		return nil, errors.New("expected drop|keep <field> <regexp>")
+	}
+
+	rule := &filterRule{name: name, field: parts[1]}
+	switch parts[0] {
+	case "drop":
+		rule.drop = true
+	case "keep":
+	default:
+		return nil, fmt.Errorf("unknown action %q", parts[0])
+	}
+
+	pattern, err := regexp.Compile(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	rule.pattern = pattern
+	return rule, nil
+}
+
+// keep reports whether the event with document doc, made from msg, is to be
+// sent.
+func (f *eventFilter) keep(doc *jsonObject, msg *router.Message) bool {
+	for _, rule := range f.rules {
+		value, ok := filterField(doc, msg, rule.field)
+		if ok && rule.pattern.MatchString(value) {
+			rule.hits.Inc(1)
+			return !rule.drop
+		}
+	}
+	return !f.dropDefault
+}
+
+// filterField returns the value of field as text.
+func filterField(doc *jsonObject, msg *router.Message, field string) (string, bool) {
+	if field == streamField {
+		return msg.Source, true
+	}
+	if strings.HasPrefix(field, labelFieldPrefix) {
+		value, ok := containerLabels(msg.Container)[strings.TrimPrefix(field, labelFieldPrefix)]
+		return value, ok
+	}
+
+	var value interface{} = doc
+	for _, key := range strings.Split(field, ".") {
+		obj := asJSONObject(value)
+		if obj == nil {
+			return "", false
+		}
+		var ok bool
+		if value, ok = obj.Get(key); !ok {
+			return "", false
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case nil, *jsonObject, []interface{}:
+		return "", false
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// asJSONObject returns value as a *jsonObject, re-encoding the sections
+// serialize adds as structs, or nil if value is not an object.
+func asJSONObject(value interface{}) *jsonObject {
+	switch value := value.(type) {
+	case *jsonObject:
+		return value
+	case nil, string, bool, json.Number, []interface{}:
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return nil
+	}
+	return obj
+}
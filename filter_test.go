@@ -0,0 +1,105 @@
+package logstash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.ID = "filtered"
+	r.Options = map[string]string{
+		"filter.1":  "drop message ^GET /health",
+		"filter.2":  "keep javaLog.level ^(WARN|ERROR)$",
+		"filter.10": "drop javaLog.level .",
+		"filter.3":  "drop label.tier ^debug$",
+	}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{
+		{"GET /health 200"},
+		{`{"message": "GET /health 200"}`},
+		{"12:00:00.000[INFO ][uuid][main][Logger]: noisy"},
+		{"12:00:00.000[ERROR][uuid][main][Logger]: failed"},
+		{"other"},
+	})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+	assert.Equal("failed", parseResult(assert, (*results)[0])["message"])
+	assert.Equal("other", parseResult(assert, (*results)[1])["message"])
+
+	var hits []int64
+	for _, rule := range adapter.filter.rules {
+		hits = append(hits, rule.hits.Count())
+	}
+	assert.Equal([]int64{2, 1, 0, 1}, hits, "rules are tried in order")
+}
+
+func TestFilterLabelsAndDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(newEventFilter("", map[string]string{"pattern": "x"}))
+	assert.Nil(newEventFilter("", map[string]string{
+		"filter.x": "drop message .",
+		"filter.1": "skip message .",
+		"filter.2": "drop message (",
+		"filter.3": "drop message",
+	}), "invalid rules are ignored")
+
+	filter := newEventFilter("", map[string]string{
+		"filter.1":       "keep label.team ^payments$",
+		"filter.2":       "keep severity ^[45]0$",
+		"filter_default": "drop",
+	})
+	doc := mustDecodeJSONObject(t, `{"message": "x", "severity": 30}`)
+	payments := makeDummyContainer("payments")
+	payments.Config.Labels = map[string]string{"team": "payments"}
+	other := makeDummyContainer("other")
+
+	assert.True(filter.keep(doc, &router.Message{Container: &payments}))
+	assert.False(filter.keep(doc, &router.Message{Container: &other}))
+	doc.Set("severity", 50)
+	assert.True(filter.keep(doc, &router.Message{Container: &other}))
+}
+
+func TestStreamFilterStream(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"filter.1": "drop stream ^stderr$"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go func() {
+		for _, msg := range []*router.Message{
+			{Container: &container, Source: "stderr", Data: "plain error"},
+			{Container: &container, Source: "stderr", Data: `{"message": "json error"}`},
+			{Container: &container, Source: "stdout", Data: "plain output"},
+			{Container: &container, Source: "stdout", Data: `{"message": "json output", "stream": "stderr"}`},
+		} {
+			msg.Time = time.Now()
+			logstream <- msg
+		}
+		close(logstream)
+	}()
+
+	adapter.Stream(logstream)
+	var messages []interface{}
+	for _, result := range *results {
+		messages = append(messages, parseResult(assert, result)["message"])
+	}
+	assert.ElementsMatch([]interface{}{"plain output", "json output"}, messages, "the stream of JSON lines is that of the container")
+}
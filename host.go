@@ -0,0 +1,168 @@
+package logstash
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const defaultHostRefresh = 5 * time.Minute
+
+// HostInfo describes the Docker host the events come from.
+type HostInfo struct {
+	Name       string   `json:"name,omitempty"`
+	OS         string   `json:"os,omitempty"`
+	Kernel     string   `json:"kernel,omitempty"`
+	IP         []string `json:"ip,omitempty"`
+	InstanceID string   `json:"instanceId,omitempty"`
+}
+
+// hostInfoSource is the part of the Docker client that describes the host.
+type hostInfoSource interface {
+	Info() (*docker.DockerInfo, error)
+}
+
+// hostMetadata keeps the host section up to date. It is collected once the
+// adapter is accepted and every host_refresh while a Docker client is watched,
+// from these route options:
+//
+//	host_metadata         host_metadata=false leaves the section out
+//	host_interfaces       network interfaces whose addresses are reported
+//	host_instance_id_file file holding the cloud instance ID, e.g.
+//	                      /var/lib/cloud/data/instance-id
+//	host_instance_id_env  environment variable holding the instance ID
+//	host_refresh          how often the section is collected again
+type hostMetadata struct {
+	interfaces     []string
+	instanceIDFile string
+	instanceIDEnv  string
+	refresh        time.Duration
+	source         hostInfoSource
+	current        atomic.Value
+	stopWatch      chan struct{}
+}
+
+// newHostMetadata returns nil when the host section is disabled.
+func newHostMetadata(options map[string]string) *hostMetadata {
+	if options["host_metadata"] == "false" {
+		return nil
+	}
+
+	refresh, err := time.ParseDuration(options["host_refresh"])
+	if err != nil || refresh <= 0 {
+		refresh = defaultHostRefresh
+	}
+
+	h := &hostMetadata{
+		interfaces:     splitList(options["host_interfaces"]),
+		instanceIDFile: options["host_instance_id_file"],
+		instanceIDEnv:  options["host_instance_id_env"],
+		refresh:        refresh,
+	}
+	return h
+}
+
+// watch collects the host section from the Docker daemon of source, now and
+// every refresh interval until stop is called.
+func (h *hostMetadata) watch(source hostInfoSource) {
+	h.source = source
+	h.collect()
+
+	ticker := time.NewTicker(h.refresh)
+	h.stopWatch = make(chan struct{})
+	go func(stop <-chan struct{}) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.collect()
+			case <-stop:
+				return
+			}
+		}
+	}(h.stopWatch)
+}
+
+// stop ends the refreshes started by watch.
+func (h *hostMetadata) stop() {
+	if h.stopWatch != nil {
+		close(h.stopWatch)
+		h.stopWatch = nil
+	}
+}
+
+// info returns the latest host section, or nil before it is first collected.
+func (h *hostMetadata) info() *HostInfo {
+	info, _ := h.current.Load().(*HostInfo)
+	return info
+}
+
+func (h *hostMetadata) collect() {
+	// the hostname of the process is that of the logspout container, so the
+	// name is only known from the daemon
+	info := &HostInfo{OS: runtime.GOOS}
+
+	if h.source != nil {
+		daemon, err := h.source.Info()
+		if err != nil {
+			log.Println("logstash: docker host info:", err)
+			if previous, ok := h.current.Load().(*HostInfo); ok {
+				info.Name, info.OS, info.Kernel = previous.Name, previous.OS, previous.Kernel
+			}
+		} else {
+			info.Name = daemon.Name
+			if daemon.OperatingSystem != "" {
+				info.OS = daemon.OperatingSystem
+			}
+			info.Kernel = daemon.KernelVersion
+		}
+	}
+
+	info.IP = interfaceAddresses(h.interfaces)
+	info.InstanceID = h.instanceID()
+	h.current.Store(info)
+}
+
+func (h *hostMetadata) instanceID() string {
+	if h.instanceIDFile != "" {
+		data, err := ioutil.ReadFile(h.instanceIDFile)
+		if err == nil {
+			return strings.TrimSpace(string(data))
+		}
+		log.Println("logstash: host instance ID:", err)
+	}
+	if h.instanceIDEnv != "" {
+		return os.Getenv(h.instanceIDEnv)
+	}
+	return ""
+}
+
+// interfaceAddresses returns the IP addresses of the named interfaces.
+func interfaceAddresses(names []string) []string {
+	var ips []string
+	for _, name := range names {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			log.Println("logstash: host interface:", err)
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Println("logstash: host interface:", err)
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				ips = append(ips, ipnet.IP.String())
+			}
+		}
+	}
+	return ips
+}
@@ -0,0 +1,123 @@
+package logstash
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHostInfo struct {
+	info *docker.DockerInfo
+	err  error
+}
+
+func (f *fakeHostInfo) Info() (*docker.DockerInfo, error) {
+	return f.info, f.err
+}
+
+func TestStreamHost(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+	adapter.host.source = &fakeHostInfo{info: &docker.DockerInfo{
+		Name:            "docker-7",
+		OperatingSystem: "Ubuntu 22.04.3 LTS",
+		KernelVersion:   "5.15.0-91-generic",
+	}}
+	adapter.host.collect()
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{
+		{"plain"},
+		{`{"message": "json"}`},
+		{`{"message": "own host", "host": "app"}`},
+	})
+
+	adapter.Stream(logstream)
+	assert.Equal(3, len(*results))
+
+	for _, result := range (*results)[:2] {
+		data := parseResult(assert, result)
+		assert.Equal(map[string]interface{}{
+			"name":   "docker-7",
+			"os":     "Ubuntu 22.04.3 LTS",
+			"kernel": "5.15.0-91-generic",
+		}, data["host"])
+	}
+	data := parseResult(assert, (*results)[2])
+	assert.Equal("app", data["host"], "application hosts are kept")
+}
+
+func TestHostMetadataOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(newHostMetadata(map[string]string{"host_metadata": "false"}))
+
+	dir, err := ioutil.TempDir("", "host")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	instanceIDFile := filepath.Join(dir, "instance-id")
+	assert.Nil(ioutil.WriteFile(instanceIDFile, []byte("i-0abc\n"), 0644))
+
+	host := newHostMetadata(map[string]string{
+		"host_instance_id_file": instanceIDFile,
+		"host_interfaces":       "lo",
+	})
+	assert.Nil(host.info(), "the section is collected once the adapter is accepted")
+	host.collect()
+	info := host.info()
+	assert.Empty(info.Name, "the hostname of the logspout container is not reported")
+	assert.Equal("i-0abc", info.InstanceID)
+	assert.Contains(info.IP, "127.0.0.1")
+
+	os.Setenv("LOGSTASH_TEST_INSTANCE_ID", "i-0def")
+	defer os.Unsetenv("LOGSTASH_TEST_INSTANCE_ID")
+	host = newHostMetadata(map[string]string{"host_instance_id_env": "LOGSTASH_TEST_INSTANCE_ID"})
+	host.source = &fakeHostInfo{err: errors.New("daemon unavailable")}
+	host.collect()
+	assert.Equal("i-0def", host.info().InstanceID)
+	assert.Empty(host.info().Name)
+
+	host.source = &fakeHostInfo{info: &docker.DockerInfo{Name: "docker-7", KernelVersion: "5.4.0"}}
+	host.collect()
+	host.source = &fakeHostInfo{err: errors.New("daemon unavailable")}
+	host.collect()
+	assert.Equal("docker-7", host.info().Name, "the daemon info is kept while the daemon is unavailable")
+	assert.Equal("5.4.0", host.info().Kernel)
+}
+
+type countingHostInfo struct {
+	calls int32
+}
+
+func (c *countingHostInfo) Info() (*docker.DockerInfo, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return &docker.DockerInfo{Name: "docker-7"}, nil
+}
+
+func TestHostMetadataWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	host := newHostMetadata(map[string]string{"host_refresh": "1ms"})
+	source := new(countingHostInfo)
+	host.watch(source)
+	assert.Equal("docker-7", host.info().Name)
+	assert.Eventually(func() bool { return atomic.LoadInt32(&source.calls) > 2 }, time.Second, time.Millisecond, "the section is refreshed")
+
+	host.stop()
+	time.Sleep(5 * time.Millisecond)
+	calls := atomic.LoadInt32(&source.calls)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(calls, atomic.LoadInt32(&source.calls), "refreshes end with stop")
+}
@@ -0,0 +1,246 @@
+package logstash
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const (
+	defaultRegistry     = "docker.io"
+	defaultDockerFields = "image_id,image_ref,created,started,restart_count,compose_project"
+)
+
+// imageInspector is the part of the Docker client used to look up the repo
+// digests of images.
+type imageInspector interface {
+	InspectImage(name string) (*docker.Image, error)
+}
+
+// dockerFields are the optional parts of the docker section, chosen with the
+// docker_fields route option:
+//
+//	image_id        ID of the image the container runs
+//	image_ref       registry, repository and tag of Config.Image, and the
+//	                digest it is pinned to or the image was pulled by
+//	created         container creation time
+//	started         time the container last started
+//	restart_count   number of restarts
+//	compose_project compose project label
+//
+// All are included by default; docker_fields= leaves them all out.
+type dockerFields struct {
+	imageID        bool
+	imageRef       bool
+	created        bool
+	started        bool
+	restartCount   bool
+	composeProject bool
+}
+
+func newDockerFields(options map[string]string) dockerFields {
+	names, ok := options["docker_fields"]
+	if !ok {
+		names = defaultDockerFields
+	}
+
+	var fields dockerFields
+	for _, name := range splitList(names) {
+		switch name {
+		case "image_id":
+			fields.imageID = true
+		case "image_ref":
+			fields.imageRef = true
+		case "created":
+			fields.created = true
+		case "started":
+			fields.started = true
+		case "restart_count":
+			fields.restartCount = true
+		case "compose_project":
+			fields.composeProject = true
+		default:
+			log.Println("logstash: unknown docker field:", name)
+		}
+	}
+	return fields
+}
+
+// dockerInfo builds the docker section of container.
+func (a *LogstashAdapter) dockerInfo(container *docker.Container) DockerInfo {
+	info := DockerInfo{
+		Name:     container.Name,
+		ID:       container.ID,
+		Image:    container.Config.Image,
+		Hostname: container.Config.Hostname,
+	}
+
+	fields := a.dockerFields
+	if fields.imageID {
+		info.ImageID = container.Image
+	}
+	if fields.imageRef && info.Image != "" {
+		ref := parseImageRef(info.Image)
+		info.Registry = ref.registry
+		info.Repository = ref.repository
+		info.Tag = ref.tag
+		info.ImageDigest = ref.digest
+		if info.ImageDigest == "" {
+			info.ImageDigest = a.imageDigests.lookup(container.Image, ref)
+		}
+	}
+	if fields.created {
+		info.Created = timeOrNil(container.Created)
+	}
+	if fields.started {
+		info.StartedAt = timeOrNil(container.State.StartedAt)
+	}
+	if fields.restartCount {
+		restartCount := container.RestartCount
+		info.RestartCount = &restartCount
+	}
+	if fields.composeProject {
+		info.ComposeProject = containerLabels(container)["com.docker.compose.project"]
+	}
+	return info
+}
+
+// imageInspectRetry is how long an image whose inspection failed is left
+// without a digest before it is inspected again.
+const imageInspectRetry = time.Minute
+
+// imageDigests resolves the repo digests of images in the background, so that
+// shipping never waits on the Docker API. Events of an image are shipped
+// without a digest until its inspection completes.
+type imageDigests struct {
+	inspector imageInspector
+
+	mu     sync.Mutex
+	images map[string]*imageDigestEntry
+}
+
+type imageDigestEntry struct {
+	repoDigests []string
+	resolved    bool
+	inspecting  bool
+	retryAt     time.Time
+}
+
+func newImageDigests(inspector imageInspector) *imageDigests {
+	return &imageDigests{
+		inspector: inspector,
+		images:    make(map[string]*imageDigestEntry),
+	}
+}
+
+// lookup returns the repo digest of the image with the given ID that matches
+// ref, starting its inspection if it is not known yet. Images built locally
+// have none.
+func (d *imageDigests) lookup(id string, ref imageRef) string {
+	if d == nil || id == "" {
+		return ""
+	}
+
+	d.mu.Lock()
+	entry, ok := d.images[id]
+	if !ok {
+		entry = &imageDigestEntry{}
+		d.images[id] = entry
+	}
+	if !entry.resolved && !entry.inspecting && !time.Now().Before(entry.retryAt) {
+		entry.inspecting = true
+		go d.inspect(id, entry)
+	}
+	repoDigests := entry.repoDigests
+	d.mu.Unlock()
+
+	return matchRepoDigest(repoDigests, ref)
+}
+
+func (d *imageDigests) inspect(id string, entry *imageDigestEntry) {
+	image, err := d.inspector.InspectImage(id)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry.inspecting = false
+	if err != nil {
+		log.Println("logstash: inspect image", id+":", err)
+		entry.retryAt = time.Now().Add(imageInspectRetry)
+		return
+	}
+	entry.repoDigests = image.RepoDigests
+	entry.resolved = true
+}
+
+// matchRepoDigest returns the digest of the repo digest naming the repository
+// of ref, or else the first one.
+func matchRepoDigest(repoDigests []string, ref imageRef) string {
+	digest := ""
+	for _, repoDigest := range repoDigests {
+		i := strings.Index(repoDigest, "@")
+		if i < 0 {
+			continue
+		}
+		if digest == "" {
+			digest = repoDigest[i+1:]
+		}
+		if repo := parseImageRef(repoDigest[:i]); repo.registry == ref.registry && repo.repository == ref.repository {
+			return repoDigest[i+1:]
+		}
+	}
+	return digest
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+type imageRef struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseImageRef splits an image reference such as
+// registry.example.com:5000/team/app:1.2@sha256:... the way Docker does:
+// the first path component names a registry only if it looks like a host,
+// official images live under library/, and the tag defaults to latest
+// unless the image is pinned by digest.
+func parseImageRef(image string) imageRef {
+	var ref imageRef
+
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.digest = name[i+1:]
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.tag = name[i+1:]
+		name = name[:i]
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+
+	ref.registry = defaultRegistry
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.registry = host
+			name = name[i+1:]
+		}
+	}
+	if ref.registry == defaultRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.repository = name
+
+	return ref
+}
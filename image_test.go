@@ -0,0 +1,159 @@
+package logstash
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamDockerFields(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	container.Image = "sha256:4f3d6ab4d3b7"
+	container.Config.Image = "registry.example.com:5000/team/app:1.2"
+	container.Created = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	container.State.StartedAt = time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC)
+	container.RestartCount = 0
+	container.Config.Labels = map[string]string{"com.docker.compose.project": "shop"}
+
+	go pump(logstream, &container, [][]string{{"plain"}, {`{"message": "json"}`}})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+
+	for _, result := range *results {
+		data := parseResult(assert, result)
+		assertDockerInfo(assert, &container, data["docker"])
+		dockerInfo := data["docker"].(map[string]interface{})
+		assert.Equal("sha256:4f3d6ab4d3b7", dockerInfo["imageId"])
+		assert.Equal("registry.example.com:5000", dockerInfo["registry"])
+		assert.Equal("team/app", dockerInfo["repository"])
+		assert.Equal("1.2", dockerInfo["tag"])
+		assert.Nil(dockerInfo["imageDigest"])
+		assert.Equal("2024-03-01T12:00:00Z", dockerInfo["created"])
+		assert.Equal("2024-03-01T12:00:05Z", dockerInfo["startedAt"])
+		assert.Equal(float64(0), dockerInfo["restartCount"])
+		assert.Equal("shop", dockerInfo["composeProject"])
+	}
+}
+
+func TestDockerFieldsToggle(t *testing.T) {
+	assert := assert.New(t)
+
+	var r router.Route
+	r.Options = map[string]string{"docker_fields": "restart_count"}
+	adapter := newLogstashAdapter(&r, nil)
+	container := makeDummyContainer("anid")
+	container.Image = "sha256:4f3d6ab4d3b7"
+	container.RestartCount = 3
+
+	info := adapter.dockerInfo(&container)
+	assert.Equal("", info.ImageID)
+	assert.Equal("", info.Repository)
+	assert.Nil(info.Created)
+	assert.Equal(3, *info.RestartCount)
+
+	r.Options = map[string]string{"docker_fields": ""}
+	adapter = newLogstashAdapter(&r, nil)
+	assert.Equal(DockerInfo{Name: "name", ID: "anid", Image: "image", Hostname: "hostname"}, adapter.dockerInfo(&container))
+}
+
+type fakeImageInspector struct {
+	mu      sync.Mutex
+	images  map[string]*docker.Image
+	inspect int
+}
+
+func (f *fakeImageInspector) InspectImage(name string) (*docker.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inspect++
+	if image, ok := f.images[name]; ok {
+		return image, nil
+	}
+	return nil, errors.New("no such image")
+}
+
+func (f *fakeImageInspector) inspections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inspect
+}
+
+func TestImageDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	adapter := newLogstashAdapter(new(router.Route), nil)
+	images := &fakeImageInspector{images: map[string]*docker.Image{
+		"sha256:4f3d6ab4d3b7": {ID: "sha256:4f3d6ab4d3b7", RepoDigests: []string{
+			"mirror.example.com/team/app@sha256:1111",
+			"registry.example.com:5000/team/app@sha256:2222",
+		}},
+		"sha256:built": {ID: "sha256:built"},
+	}}
+	adapter.imageDigests = newImageDigests(images)
+	digest := func(container *docker.Container) func() bool {
+		return func() bool { return adapter.dockerInfo(container).ImageDigest != "" }
+	}
+
+	container := makeDummyContainer("anid")
+	container.Image = "sha256:4f3d6ab4d3b7"
+	container.Config.Image = "registry.example.com:5000/team/app:1.2"
+	assert.Eventually(digest(&container), time.Second, time.Millisecond, "digests are resolved in the background")
+	assert.Equal("sha256:2222", adapter.dockerInfo(&container).ImageDigest, "the digest of the repository the container was created from")
+	assert.Equal(1, images.inspections(), "digests are looked up once per image")
+
+	container.Config.Image = "app@sha256:3333"
+	assert.Equal("sha256:3333", adapter.dockerInfo(&container).ImageDigest, "a pinned digest is used as is")
+
+	container.Config.Image = "mirror.example.com/team/app"
+	container.Image = "sha256:mirrored"
+	assert.Equal("", adapter.dockerInfo(&container).ImageDigest)
+	assert.Eventually(func() bool { return images.inspections() == 2 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal("", adapter.dockerInfo(&container).ImageDigest)
+	assert.Equal(2, images.inspections(), "failed inspections are retried after a while")
+
+	images.mu.Lock()
+	images.images["sha256:mirrored"] = &docker.Image{RepoDigests: []string{
+		"registry.example.com:5000/team/app@sha256:2222",
+		"mirror.example.com/team/app@sha256:1111",
+	}}
+	images.mu.Unlock()
+	adapter.imageDigests.mu.Lock()
+	adapter.imageDigests.images["sha256:mirrored"].retryAt = time.Now()
+	adapter.imageDigests.mu.Unlock()
+	assert.Eventually(digest(&container), time.Second, time.Millisecond)
+	assert.Equal("sha256:1111", adapter.dockerInfo(&container).ImageDigest)
+
+	container.Config.Image = "app"
+	container.Image = "sha256:built"
+	adapter.dockerInfo(&container)
+	assert.Eventually(func() bool { return images.inspections() == 4 }, time.Second, time.Millisecond)
+	assert.Equal("", adapter.dockerInfo(&container).ImageDigest, "local builds have no digest")
+}
+
+func TestParseImageRef(t *testing.T) {
+	assert := assert.New(t)
+
+	for image, expected := range map[string]imageRef{
+		"nginx":                      {"docker.io", "library/nginx", "latest", ""},
+		"bitnami/redis:7.2":          {"docker.io", "bitnami/redis", "7.2", ""},
+		"localhost/app":              {"localhost", "app", "latest", ""},
+		"localhost:5000/app:dev":     {"localhost:5000", "app", "dev", ""},
+		"ghcr.io/org/tool@sha256:ab": {"ghcr.io", "org/tool", "", "sha256:ab"},
+		"quay.io/a/b:1.0@sha256:cd":  {"quay.io", "a/b", "1.0", "sha256:cd"},
+	} {
+		assert.Equal(expected, parseImageRef(image), image)
+	}
+}
@@ -0,0 +1,160 @@
+package logstash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// jsonObject is a decoded JSON object that remembers the order of its keys, so
+// that application JSON is re-encoded the way it was written. Nested objects
+// are *jsonObject, arrays are []interface{} and numbers are json.Number, which
+// keeps 64-bit integers such as snowflake IDs exact.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]interface{})}
+}
+
+// decodeJSONObject decodes data, which must hold exactly one JSON object.
+func decodeJSONObject(data []byte) (*jsonObject, error) {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := value.(*jsonObject)
+	if !ok {
+		return nil, errors.New("json: not an object")
+	}
+	return obj, nil
+}
+
+// decodeJSON decodes a single JSON value of any type.
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("json: unexpected data after top-level value")
+	}
+	return value, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := newJSONObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.Set(key.(string), value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return array, nil
+	default:
+		return tok, nil
+	}
+}
+
+// Get returns the value stored under key. It is safe to call on a nil object.
+func (o *jsonObject) Get(key string) (interface{}, bool) {
+	if o == nil {
+		return nil, false
+	}
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// Set stores value under key. New keys are appended after the existing ones,
+// existing keys keep their position.
+func (o *jsonObject) Set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// Delete removes key from the object.
+func (o *jsonObject) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys of the object in order.
+func (o *jsonObject) Keys() []string {
+	if o == nil {
+		return nil
+	}
+	return o.keys
+}
+
+// Len returns the number of keys in the object.
+func (o *jsonObject) Len() int {
+	return len(o.Keys())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.Keys() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		encodedValue, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,98 @@
+package logstash
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONObjectRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 64-bit IDs survive unchanged
+		{`{"id":1234567890123456789,"neg":-9223372036854775808,"max":18446744073709551615}`,
+			`{"id":1234567890123456789,"neg":-9223372036854775808,"max":18446744073709551615}`},
+		// number literals are kept as written
+		{`{"f":1.50,"e":1e+30,"z":0}`, `{"f":1.50,"e":1e+30,"z":0}`},
+		// key order is preserved, including for nested objects
+		{`{"z":1,"a":{"y":true,"b":null},"m":"x"}`, `{"z":1,"a":{"y":true,"b":null},"m":"x"}`},
+		// nested arrays
+		{`{"a":[[1,[2,{"k":[3]}]],[],{}]}`, `{"a":[[1,[2,{"k":[3]}]],[],{}]}`},
+		// literal UTF-8 is kept as is
+		{`{"s":"café 😀","kéy":"\n"}`, `{"s":"café 😀","kéy":"\n"}`},
+		// unicode escapes, including surrogate pairs, are re-encoded as
+		// UTF-8, in keys as well as values
+		{`{"s":"\u00e9\ud83d\ude00","k\u00e9y":"\u0041"}`, `{"s":"é😀","kéy":"A"}`},
+		// except the characters Go escapes for HTML and JavaScript safety
+		{`{"h":"<a href=\"x\">&</a>","l":"\u2028\u2029"}`,
+			`{"h":"\u003ca href=\"x\"\u003e\u0026\u003c/a\u003e","l":"\u2028\u2029"}`},
+		// a lone surrogate decodes to U+FFFD, shipped as UTF-8
+		{`{"bad":"\ud83d"}`, "{\"bad\":\"\uFFFD\"}"},
+		// whitespace is normalised
+		{"{ \"a\" :\n 1 }\n", `{"a":1}`},
+	}
+
+	for _, test := range tests {
+		obj, err := decodeJSONObject([]byte(test.input))
+		assert.Nil(t, err, test.input)
+
+		encoded, err := json.Marshal(obj)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, string(encoded))
+	}
+}
+
+func TestJSONObjectRejectsNonObjects(t *testing.T) {
+	for _, input := range []string{``, `[1]`, `"s"`, `{"a":1} {"b":2}`, `{"a":}`, `{"a":1`} {
+		_, err := decodeJSONObject([]byte(input))
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestJSONObjectSetDelete(t *testing.T) {
+	obj := mustDecodeJSONObject(t, `{"b":1,"a":2,"c":3}`)
+
+	obj.Set("a", "replaced")
+	obj.Set("d", 4)
+	obj.Delete("b")
+	obj.Delete("missing")
+
+	assert.Equal(t, []string{"a", "c", "d"}, obj.Keys())
+	assert.Equal(t, 3, obj.Len())
+
+	encoded, _ := json.Marshal(obj)
+	assert.Equal(t, `{"a":"replaced","c":3,"d":4}`, string(encoded))
+}
+
+func TestStreamJSONPreservesPrecisionAndOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{{
+		`{"zeta":"first","id":1311768467463790320,"alpha":[1.0,{"b":2,"a":1}],"message":"hi"}`,
+	}})
+
+	adapter.Stream(logstream)
+	result := (*results)[0]
+
+	assert.True(strings.HasPrefix(result,
+		`{"zeta":"first","id":1311768467463790320,"alpha":[1.0,{"b":2,"a":1}],"message":"hi",`), result)
+}
+
+func mustDecodeJSONObject(t *testing.T, s string) *jsonObject {
+	obj, err := decodeJSONObject([]byte(s))
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", s, err)
+	}
+	return obj
+}
@@ -0,0 +1,128 @@
+package logstash
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/logspout/router"
+)
+
+// klog header: Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+var klogRegExp = regexp.MustCompile(
+	`(?s)^([IWEF])(\d{2})(\d{2}) (\d{2}):(\d{2}):(\d{2})\.(\d{6})\s+(\d+) ([^:\s\]]+):(\d+)\] ?(.*)$`)
+
+var klogSeverities = map[string]string{
+	"I": "INFO",
+	"W": "WARNING",
+	"E": "ERROR",
+	"F": "FATAL",
+}
+
+// KlogLog holds the header fields of a klog/glog formatted line, as written by
+// Kubernetes components and anything else built on client-go.
+type KlogLog struct {
+	Severity  string            `json:"severity"`
+	Timestamp string            `json:"timestamp"`
+	ThreadID  string            `json:"threadId"`
+	File      string            `json:"file"`
+	Line      int               `json:"line"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+func parseKlog(a *LogstashAdapter, msg *router.Message, parsed *parsedLog) bool {
+	klog, message := parseKlogMsg(msg.Data, msg.Time)
+	if klog == nil {
+		return false
+	}
+	parsed.Klog = klog
+	parsed.Message = message
+	return true
+}
+
+// parseKlogMsg decodes a klog line. klog omits the year, so it is taken from
+// received, stepping back a year when that would put the line in the future.
+func parseKlogMsg(data string, received time.Time) (*KlogLog, string) {
+	match := klogRegExp.FindStringSubmatch(data)
+	if match == nil {
+		return nil, data
+	}
+
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	if received.IsZero() {
+		received = time.Now()
+	}
+	timestamp := time.Date(received.Year(), time.Month(atoi(match[2])), atoi(match[3]),
+		atoi(match[4]), atoi(match[5]), atoi(match[6]), atoi(match[7])*1000, received.Location())
+	if timestamp.Sub(received) > 24*time.Hour {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+
+	message, fields := parseKlogStructured(strings.TrimRight(match[11], " \t\n\r"))
+
+	klog := KlogLog{
+		Severity:  klogSeverities[match[1]],
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+		ThreadID:  match[8],
+		File:      match[9],
+		Line:      atoi(match[10]),
+		Fields:    fields,
+	}
+	return &klog, message
+}
+
+// parseKlogStructured splits the output of klog's structured calls (InfoS,
+// ErrorS) of the form `"msg" key="value" key2=value2` into the message and its
+// key/value pairs. Anything else is returned unchanged with nil fields.
+func parseKlogStructured(s string) (string, map[string]string) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return s, nil
+	}
+	message, _ := strconv.Unquote(quoted)
+
+	fields := make(map[string]string)
+	rest := s[len(quoted):]
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			break
+		}
+
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || strings.ContainsAny(rest[:eq], " \"") {
+			return s, nil
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return s, nil
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else if end := strings.IndexByte(rest, ' '); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+		fields[key] = value
+	}
+
+	if len(fields) == 0 {
+		fields = nil
+	}
+	return message, fields
+}
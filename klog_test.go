@@ -0,0 +1,76 @@
+package logstash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamKlog(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	lines := []string{
+		`E1016 12:00:00.000123    1 controller.go:42] "Failed to sync pod" pod="kube-system/coredns" err="context deadline exceeded" retries=3`,
+	}
+
+	go pump(logstream, &container, [][]string{lines})
+
+	adapter.Stream(logstream)
+	data := parseResult(assert, (*results)[0])
+
+	assert.Equal("Failed to sync pod", data["message"])
+	assert.Nil(data["javaLog"])
+
+	klog := data["klog"].(map[string]interface{})
+	assert.Equal("ERROR", klog["severity"])
+	assert.Equal("1", klog["threadId"])
+	assert.Equal("controller.go", klog["file"])
+	assert.Equal(float64(42), klog["line"])
+	assert.Equal(map[string]interface{}{
+		"pod":     "kube-system/coredns",
+		"err":     "context deadline exceeded",
+		"retries": "3",
+	}, klog["fields"])
+}
+
+func TestParseKlogPlainMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	received := time.Date(2026, time.October, 16, 12, 0, 1, 0, time.UTC)
+	klog, message := parseKlogMsg("I1016 12:00:00.000000    7 main.go:10] Starting controller v1.2", received)
+
+	assert.NotNil(klog)
+	assert.Equal("Starting controller v1.2", message)
+	assert.Equal("INFO", klog.Severity)
+	assert.Equal("2026-10-16T12:00:00Z", klog.Timestamp)
+	assert.Equal("7", klog.ThreadID)
+	assert.Nil(klog.Fields)
+}
+
+func TestParseKlogYearRollover(t *testing.T) {
+	received := time.Date(2027, time.January, 1, 0, 0, 1, 0, time.UTC)
+	klog, _ := parseKlogMsg("W1231 23:59:59.500000    1 main.go:10] late", received)
+
+	assert.Equal(t, "2026-12-31T23:59:59.5Z", klog.Timestamp)
+}
+
+func TestParseKlogNoMatch(t *testing.T) {
+	klog, message := parseKlogMsg("just a line", time.Now())
+
+	assert.Nil(t, klog)
+	assert.Equal(t, "just a line", message)
+}
+
+func TestParseKlogStructuredMalformed(t *testing.T) {
+	message, fields := parseKlogStructured(`"unterminated key="value`)
+
+	assert.Equal(t, `"unterminated key="value`, message)
+	assert.Nil(t, fields)
+}
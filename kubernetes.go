@@ -0,0 +1,30 @@
+package logstash
+
+import (
+	"github.com/fsouza/go-dockerclient"
+)
+
+// KubernetesInfo describes the pod a container runs in, taken from the
+// labels dockershim and cri-dockerd put on the containers they create.
+type KubernetesInfo struct {
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	Container string `json:"container"`
+	PodUID    string `json:"podUid,omitempty"`
+}
+
+// kubernetesInfo returns the kubernetes section of container, or nil for
+// containers not started by the kubelet.
+func kubernetesInfo(container *docker.Container) *KubernetesInfo {
+	labels := containerLabels(container)
+	pod := labels["io.kubernetes.pod.name"]
+	if pod == "" {
+		return nil
+	}
+	return &KubernetesInfo{
+		Pod:       pod,
+		Namespace: labels["io.kubernetes.pod.namespace"],
+		Container: labels["io.kubernetes.container.name"],
+		PodUID:    labels["io.kubernetes.pod.uid"],
+	}
+}
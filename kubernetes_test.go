@@ -0,0 +1,57 @@
+package logstash
+
+import (
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamKubernetes(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	container.Name = "/k8s_api_api-7d9c8b6f5-xk2lp_shop_0f6a3c1e-3b1d-4a7e-9a55-2b9f6f0c1d2e_0"
+	container.Config.Labels = map[string]string{
+		"io.kubernetes.pod.name":       "api-7d9c8b6f5-xk2lp",
+		"io.kubernetes.pod.namespace":  "shop",
+		"io.kubernetes.container.name": "api",
+		"io.kubernetes.pod.uid":        "0f6a3c1e-3b1d-4a7e-9a55-2b9f6f0c1d2e",
+	}
+
+	go pump(logstream, &container, [][]string{{"plain"}, {`{"message": "json"}`}})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+
+	for _, result := range *results {
+		data := parseResult(assert, result)
+		assert.Equal(map[string]interface{}{
+			"pod":       "api-7d9c8b6f5-xk2lp",
+			"namespace": "shop",
+			"container": "api",
+			"podUid":    "0f6a3c1e-3b1d-4a7e-9a55-2b9f6f0c1d2e",
+		}, data["kubernetes"])
+		component := data["component"].(map[string]interface{})
+		assert.Equal("api", component["name"])
+		assert.Nil(data["swarm"])
+	}
+}
+
+func TestKubernetesInfoOutsideKubernetes(t *testing.T) {
+	assert := assert.New(t)
+
+	container := makeDummyContainer("anid")
+	assert.Nil(kubernetesInfo(&container))
+
+	container.Config.Labels = map[string]string{
+		"io.kubernetes.pod.name":       "api-7d9c8b6f5-xk2lp",
+		"io.kubernetes.container.name": "api",
+		"com.docker.compose.service":   "compose",
+	}
+	assert.Equal("compose", serviceName(&container))
+}
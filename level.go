@@ -0,0 +1,200 @@
+package logstash
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlabs/logspout/router"
+)
+
+// Canonical levels, in increasing order of severity. The numeric values follow
+// bunyan/pino so that numeric levels from those loggers map onto themselves.
+const (
+	levelTrace = "trace"
+	levelDebug = "debug"
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+	levelFatal = "fatal"
+)
+
+var levelSeverities = map[string]int{
+	levelTrace: 10,
+	levelDebug: 20,
+	levelInfo:  30,
+	levelWarn:  40,
+	levelError: 50,
+	levelFatal: 60,
+}
+
+var defaultLevelMap = map[string]string{
+	"trace":         levelTrace,
+	"finest":        levelTrace,
+	"finer":         levelTrace,
+	"debug":         levelDebug,
+	"dbg":           levelDebug,
+	"fine":          levelDebug,
+	"config":        levelDebug,
+	"info":          levelInfo,
+	"information":   levelInfo,
+	"informational": levelInfo,
+	"notice":        levelInfo,
+	"warn":          levelWarn,
+	"warning":       levelWarn,
+	"error":         levelError,
+	"err":           levelError,
+	"severe":        levelError,
+	"fatal":         levelFatal,
+	"critical":      levelFatal,
+	"crit":          levelFatal,
+	"panic":         levelFatal,
+	"alert":         levelFatal,
+	"emerg":         levelFatal,
+	"emergency":     levelFatal,
+}
+
+const defaultLevelKeys = "level,severity,lvl,loglevel,log_level,levelname"
+
+const defaultStreamLevels = "stdout:info,stderr:error"
+
+// levelNormalizer maps the many spellings of a log level onto the canonical
+// levels above.
+type levelNormalizer struct {
+	mapping      map[string]string
+	keys         []string
+	streamLevels map[string]string
+}
+
+// newLevelNormalizer builds a levelNormalizer from the route options:
+//
+//	level_map     extra name:level pairs, e.g. "notice:warn,crit:error"
+//	level_keys    JSON keys checked for a level, in order
+//	stream_levels source:level fallbacks, empty to disable
+func newLevelNormalizer(options map[string]string) *levelNormalizer {
+	mapping := make(map[string]string)
+	for name, level := range defaultLevelMap {
+		mapping[name] = level
+	}
+	for name, level := range splitPairs(options["level_map"]) {
+		mapping[strings.ToLower(name)] = strings.ToLower(level)
+	}
+
+	keys, ok := options["level_keys"]
+	if !ok {
+		keys = defaultLevelKeys
+	}
+
+	streamLevels, ok := options["stream_levels"]
+	if !ok {
+		streamLevels = defaultStreamLevels
+	}
+
+	return &levelNormalizer{
+		mapping:      mapping,
+		keys:         splitList(keys),
+		streamLevels: splitPairs(streamLevels),
+	}
+}
+
+// normalize returns the canonical level and numeric severity of an event,
+// preferring parser output, then JSON fields, then the message source. An
+// empty level is returned when none of them yields one.
+func (n *levelNormalizer) normalize(msg *router.Message, parsed *parsedLog, jsonMsg *jsonObject) (string, int) {
+	level := n.explicit(parsed, jsonMsg)
+	if level == "" {
+		level = n.lookup(n.streamLevels[msg.Source])
+	}
+	if level == "" {
+		return "", 0
+	}
+	return level, levelSeverities[level]
+}
+
+// explicit returns the canonical level the event itself states, in parser
+// output or JSON fields, ignoring the message source.
+func (n *levelNormalizer) explicit(parsed *parsedLog, jsonMsg *jsonObject) string {
+	var candidates []interface{}
+	if parsed.JavaLog != nil {
+		candidates = append(candidates, parsed.JavaLog.Level)
+	}
+	if parsed.Klog != nil {
+		candidates = append(candidates, parsed.Klog.Severity)
+	}
+	for _, key := range n.keys {
+		if value, ok := jsonMsg.Get(key); ok {
+			candidates = append(candidates, value)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if level := n.lookup(candidate); level != "" {
+			return level
+		}
+	}
+	return ""
+}
+
+func (n *levelNormalizer) lookup(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		name := strings.ToLower(strings.TrimSpace(v))
+		if level, ok := n.mapping[name]; ok {
+			return level
+		}
+		if number, err := strconv.ParseFloat(name, 64); err == nil {
+			return numericLevel(number)
+		}
+	case float64:
+		return numericLevel(v)
+	case json.Number:
+		if number, err := v.Float64(); err == nil {
+			return numericLevel(number)
+		}
+	}
+	return ""
+}
+
+// numericLevel maps bunyan/pino numeric levels onto canonical levels.
+func numericLevel(number float64) string {
+	switch {
+	case number <= 0:
+		return ""
+	case number <= 10:
+		return levelTrace
+	case number <= 20:
+		return levelDebug
+	case number <= 30:
+		return levelInfo
+	case number <= 40:
+		return levelWarn
+	case number <= 50:
+		return levelError
+	default:
+		return levelFatal
+	}
+}
+
+// splitList splits a comma separated option value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitPairs parses a comma separated list of key:value pairs.
+func splitPairs(value string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range splitList(value) {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) == 2 {
+			pairs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,95 @@
+package logstash
+
+import (
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelNormalization(t *testing.T) {
+	n := newLevelNormalizer(map[string]string{})
+
+	tests := []struct {
+		source   string
+		parsed   parsedLog
+		jsonMsg  string
+		level    string
+		severity int
+	}{
+		{"stdout", parsedLog{JavaLog: &JavaLog{Level: "WARN "}}, "", "warn", 40},
+		{"stdout", parsedLog{Klog: &KlogLog{Severity: "ERROR"}}, "", "error", 50},
+		{"stdout", parsedLog{}, `{"levelname":"warning"}`, "warn", 40},
+		{"stdout", parsedLog{}, `{"level":30}`, "info", 30},
+		{"stdout", parsedLog{}, `{"level":60}`, "fatal", 60},
+		{"stdout", parsedLog{}, `{"severity":"CRITICAL"}`, "fatal", 60},
+		{"stdout", parsedLog{}, `{"level":"bogus"}`, "info", 30},
+		{"stderr", parsedLog{}, "", "error", 50},
+		{"other", parsedLog{}, "", "", 0},
+	}
+
+	for _, test := range tests {
+		msg := router.Message{Source: test.source}
+		jsonMsg, _ := decodeJSONObject([]byte(test.jsonMsg))
+		level, severity := n.normalize(&msg, &test.parsed, jsonMsg)
+		assert.Equal(t, test.level, level)
+		assert.Equal(t, test.severity, severity)
+	}
+}
+
+func TestLevelNormalizationOptions(t *testing.T) {
+	n := newLevelNormalizer(map[string]string{
+		"level_map":     "notice:warn,Verbose:trace",
+		"level_keys":    "lvl",
+		"stream_levels": "",
+	})
+
+	msg := router.Message{Source: "stderr"}
+
+	level, _ := n.normalize(&msg, &parsedLog{}, mustDecodeJSONObject(t, `{"lvl":"NOTICE"}`))
+	assert.Equal(t, "warn", level)
+
+	level, _ = n.normalize(&msg, &parsedLog{}, mustDecodeJSONObject(t, `{"lvl":"verbose"}`))
+	assert.Equal(t, "trace", level)
+
+	level, _ = n.normalize(&msg, &parsedLog{}, mustDecodeJSONObject(t, `{"level":"error"}`))
+	assert.Equal(t, "", level, "only configured keys are read and stream fallback is disabled")
+}
+
+func TestStreamLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{
+		{`{"msg":"hello","level":"ERR"}`},
+		{"12:55:46.650[WARN ][6d3b36a5][main]o.e.Foo : careful"},
+		{`{"msg":"pino","level":30,"severity":"WARNING"}`},
+		{`{"message":"python","levelname":"warning"}`},
+		{`{"msg":"odd","level":"bogus"}`},
+	})
+
+	adapter.Stream(logstream)
+
+	for i, expected := range []struct {
+		level    string
+		severity float64
+	}{
+		{"error", 50},
+		{"warn", 40},
+		{"info", 30},
+		{"warn", 40},
+	} {
+		data := parseResult(assert, (*results)[i])
+		assert.Equal(expected.level, data["level"], "event %d", i)
+		assert.Equal(expected.severity, data["severity"], "event %d", i)
+	}
+
+	data := parseResult(assert, (*results)[4])
+	assert.Equal("bogus", data["level"], "unknown levels are kept")
+	assert.Nil(data["severity"])
+}
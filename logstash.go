@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/fsouza/go-dockerclient"
 	"github.com/gliderlabs/logspout/router"
 	"github.com/rcrowley/go-metrics"
 	"github.com/rcrowley/go-metrics/exp"
@@ -27,7 +28,7 @@ func init() {
 	metrics.Register("logstash_message_rate", logMeter)
 }
 
-type newMultilineBufferFn func() (multiline.MultiLine, error)
+type newMultilineBufferFn func(container *docker.Container) (multiline.MultiLine, error)
 
 // LogstashAdapter is an adapter that streams TCP JSON to Logstash.
 type LogstashAdapter struct {
@@ -36,11 +37,42 @@ type LogstashAdapter struct {
 	cache            map[string]*multiline.MultiLine
 	cacheTTL         time.Duration
 	cachedLines      metrics.Gauge
+	cachedBytes      metrics.Gauge
 	mkBuffer         newMultilineBufferFn
+	multilineConfig  multiline.MultilineConfig
+	multilineConfigs map[string]*multiline.MultilineConfig
 	cleanupRegExp    *regexp.Regexp
 	javaLogRegExp    *regexp.Regexp
 	staskTraceRegExp *regexp.Regexp
 	causeRegExp      *regexp.Regexp
+	parsers          []parserFn
+	levels           *levelNormalizer
+	embeddedJSON     *embeddedJSONDecoder
+	mixedJSON        bool
+	partials         *partialAssembler
+	streams          map[string]map[string]bool
+	ordered          bool
+	held             map[string][]*multiline.Event
+	sequences        map[string]uint64
+	deadlines        *deadlineQueue
+	ages             *deadlineQueue
+	pendingLines     int64
+	pendingBytes     int64
+	maxPendingBytes  int64
+	containerEvents  chan *docker.APIEvents
+	metadata         *metadataExporter
+	staticFields     *staticFields
+	host             *hostMetadata
+	dockerFields     dockerFields
+	imageDigests     *imageDigests
+	format           string
+	containerOptions map[string]*containerOptions
+	filter           *eventFilter
+	minSeverity      int
+	threshold        *levelThreshold
+	redactor         *redactor
+	stopped          []string
+	removed          *deadlineQueue
 }
 
 type ControlCode int
@@ -77,11 +109,39 @@ func newLogstashAdapter(route *router.Route, write writer) *LogstashAdapter {
 		maxLines = 0
 	}
 
+	maxBytes, err := strconv.Atoi(route.Options["max_bytes"])
+	if err != nil {
+		maxBytes = 0
+	}
+
+	var startPattern, endPattern *regexp.Regexp
+	if startPatternString, ok := route.Options["start_pattern"]; ok {
+		startPattern = regexp.MustCompile(startPatternString)
+	}
+	if endPatternString, ok := route.Options["end_pattern"]; ok {
+		endPattern = regexp.MustCompile(endPatternString)
+	}
+
+	maxPendingBytes, err := strconv.ParseInt(route.Options["max_pending_bytes"], 10, 64)
+	if err != nil {
+		maxPendingBytes = 0
+	}
+
 	cacheTTL, err := time.ParseDuration(route.Options["cache_ttl"])
 	if err != nil {
 		cacheTTL = 10 * time.Second
 	}
 
+	idleTimeout, err := time.ParseDuration(route.Options["idle_timeout"])
+	if err != nil {
+		idleTimeout = 0
+	}
+
+	maxDuration, err := time.ParseDuration(route.Options["max_duration"])
+	if err != nil {
+		maxDuration = 0
+	}
+
 	cleanupPattern, ok := route.Options["cleanup_pattern"]
 	if !ok {
 		cleanupPattern = `\033\[[0-9;]*?m`
@@ -102,6 +162,18 @@ func newLogstashAdapter(route *router.Route, write writer) *LogstashAdapter {
 		causePattern = `^(.*?):\s(.*)`
 	}
 
+	mixedJSON := route.Options["mixed_json"] != "false"
+
+	format, ok := route.Options["format"]
+	if !ok || !validFormat(format) {
+		format = formatJSON
+	}
+
+	parserNames, ok := route.Options["parsers"]
+	if !ok {
+		parserNames = "java,klog"
+	}
+
 	cleanupRegExp := regexp.MustCompile(cleanupPattern)
 	javaLogRegExp := regexp.MustCompile(javaLogPattern)
 	staskTraceRegExp := regexp.MustCompile(stacktracePattern)
@@ -109,28 +181,59 @@ func newLogstashAdapter(route *router.Route, write writer) *LogstashAdapter {
 
 	cachedLines := metrics.NewGauge()
 	metrics.Register(route.ID + "_cached_lines", cachedLines)
+	cachedBytes := metrics.NewGauge()
+	metrics.Register(route.ID + "_cached_bytes", cachedBytes)
 
-	return &LogstashAdapter{
+	adapter := &LogstashAdapter{
 		route:       route,
 		write:       write,
 		cache:       make(map[string]*multiline.MultiLine),
 		cacheTTL:    cacheTTL,
 		cachedLines: cachedLines,
-		mkBuffer: func() (multiline.MultiLine, error) {
-			return multiline.NewMultiLine(
-				&multiline.MultilineConfig{
-					Pattern:   regexp.MustCompile(patternString),
-					GroupWith: groupWith,
-					Negate:    negate,
-					Separator: &separator,
-					MaxLines:  maxLines,
-				})
+		cachedBytes: cachedBytes,
+		multilineConfig: multiline.MultilineConfig{
+			Pattern:   regexp.MustCompile(patternString),
+			GroupWith: groupWith,
+			Negate:    negate,
+			Separator: &separator,
+			MaxLines:  maxLines,
+			MaxBytes:  maxBytes,
+			StartPattern: startPattern,
+			EndPattern:   endPattern,
+			MaxDuration:  maxDuration,
+			IdleTimeout:  idleTimeout,
 		},
+		multilineConfigs: make(map[string]*multiline.MultilineConfig),
 		cleanupRegExp : cleanupRegExp,
 		javaLogRegExp : javaLogRegExp,
 		staskTraceRegExp : staskTraceRegExp,
 		causeRegExp : causeRegExp,
-	}
+		parsers : lookupParsers(parserNames),
+		levels : newLevelNormalizer(route.Options),
+		embeddedJSON : newEmbeddedJSONDecoder(route.Options),
+		mixedJSON : mixedJSON,
+		partials : newPartialAssembler(route.Options),
+		streams : make(map[string]map[string]bool),
+		ordered : route.Options["ordering"] == "time",
+		held : make(map[string][]*multiline.Event),
+		sequences : make(map[string]uint64),
+		deadlines : newDeadlineQueue(),
+		ages : newDeadlineQueue(),
+		maxPendingBytes : maxPendingBytes,
+		metadata : newMetadataExporter(route.Options),
+		staticFields : newStaticFields(route.Options),
+		host : newHostMetadata(route.Options),
+		dockerFields : newDockerFields(route.Options),
+		format : format,
+		containerOptions : make(map[string]*containerOptions),
+		filter : newEventFilter(route.ID, route.Options),
+		threshold : newLevelThreshold(route.ID, route.Options),
+		redactor : newRedactor(route.Options),
+		removed : newDeadlineQueue(),
+	}
+	adapter.minSeverity = adapter.levels.minSeverity(route.Options["min_level"])
+	adapter.mkBuffer = adapter.newContainerBuffer
+	return adapter
 }
 
 // NewLogstashAdapter creates a LogstashAdapter with TCP as the default transport.
@@ -145,6 +248,11 @@ func NewLogstashAdapter(route *router.Route) (router.LogAdapter, error) {
 		return nil, errors.New("unable to find adapter: " + route.Adapter)
 	}
 
+	adapter := newLogstashAdapter(route, nil)
+	if err := adapter.validateMultiline(); err != nil {
+		return nil, err
+	}
+
 	conn, err := transport.Dial(route.Address, route.Options)
 	if err != nil {
 		return nil, err
@@ -157,41 +265,117 @@ func NewLogstashAdapter(route *router.Route) (router.LogAdapter, error) {
 		write = defaultWriter(conn)
 	}
 
-	return newLogstashAdapter(route, write), nil
+	adapter.write = write
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		log.Println("logstash: no docker client:", err)
+		if adapter.host != nil {
+			log.Println("logstash: the host section has no name or kernel without a docker client")
+			adapter.host.collect()
+		}
+		return adapter, nil
+	}
+	if route.Options["container_events"] != "false" {
+		if err := adapter.watchContainers(client); err != nil {
+			log.Println("logstash: not watching container events:", err)
+		}
+	}
+	if adapter.host != nil {
+		adapter.host.watch(client)
+	}
+	adapter.imageDigests = newImageDigests(client)
+
+	return adapter, nil
 }
 
+// lookupBuffer returns the buffer of the stream of msg, or nil when no buffer
+// can be created for it.
 func (a *LogstashAdapter) lookupBuffer(msg *router.Message) *multiline.MultiLine {
 	key := msg.Container.ID + msg.Source
 	if a.cache[key] == nil {
-		ml, _ := a.mkBuffer()
+		ml, err := a.mkBuffer(msg.Container)
+		if err != nil {
+			log.Println("logstash: no multiline buffer for container", msg.Container.ID+":", err)
+			return nil
+		}
 		a.cache[key] = &ml
+		if a.streams[msg.Container.ID] == nil {
+			a.streams[msg.Container.ID] = make(map[string]bool)
+		}
+		a.streams[msg.Container.ID][msg.Source] = true
 	}
 	return a.cache[key]
 }
 
 // Stream implements the router.LogAdapter interface.
 func (a *LogstashAdapter) Stream(logstream chan *router.Message) {
-	cacheTicker := time.NewTicker(a.cacheTTL).C
+	expireTimer := time.NewTimer(a.cacheTTL)
 
 	for {
-		msgs, ccode := a.readMessages(logstream, cacheTicker)
+		a.resetExpireTimer(expireTimer)
+		msgs, ccode := a.readMessages(logstream, expireTimer.C)
+		if a.ordered {
+			msgs = a.orderEvents(msgs, ccode == Quit)
+		}
 		a.sendMessages(msgs)
+		a.forgetStoppedContainers()
 
 		switch ccode {
 		case Continue:
 			continue
 		case Quit:
+			if a.host != nil {
+				a.host.stop()
+			}
 			return
 		}
 	}
 }
 
+// resetExpireTimer arms timer for the earliest deadline of the multiline
+// buffers, partial messages and removed containers, or for the cache TTL when
+// nothing is pending.
+func (a *LogstashAdapter) resetExpireTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	deadline, ok := a.nextDeadline()
+	if !ok {
+		timer.Reset(a.cacheTTL)
+		return
+	}
+	timer.Reset(time.Until(deadline))
+}
+
+func (a *LogstashAdapter) nextDeadline() (time.Time, bool) {
+	deadline, ok := a.deadlines.next()
+	if removed, found := a.removed.next(); found && (!ok || removed.Before(deadline)) {
+		deadline, ok = removed, true
+	}
+	if a.partials != nil {
+		if partial, found := a.partials.deadlines.next(); found && (!ok || partial.Before(deadline)) {
+			deadline, ok = partial, true
+		}
+	}
+	return deadline, ok
+}
+
 func (a *LogstashAdapter) readMessages(
 logstream chan *router.Message,
-cacheTicker <-chan time.Time) ([]*router.Message, ControlCode) {
+expireTimer <-chan time.Time) ([]*multiline.Event, ControlCode) {
 	select {
-	case t := <-cacheTicker:
+	case t := <-expireTimer:
 		return a.expireCache(t), Continue
+	case event, ok := <-a.containerEvents:
+		if !ok {
+			a.containerEvents = nil
+			return nil, Continue
+		}
+		return a.handleContainerEvent(event), Continue
 	case msg, ok := <-logstream:
 		if ok {
 			return a.bufferMessage(msg), Continue
@@ -201,36 +385,137 @@ cacheTicker <-chan time.Time) ([]*router.Message, ControlCode) {
 	}
 }
 
-func (a *LogstashAdapter) bufferMessage(msg *router.Message) []*router.Message {
-	msgOrNil := a.lookupBuffer(msg).Buffer(msg)
+func (a *LogstashAdapter) bufferMessage(msg *router.Message) []*multiline.Event {
+	if a.removed.has(msg.Container.ID) {
+		// a late line of a stopped container, whose state is forgotten
+		// again once the line has been shipped
+		a.stopped = append(a.stopped, msg.Container.ID)
+	}
+	if !a.optionsFor(msg.Container).enabled {
+		return []*multiline.Event{}
+	}
+	if a.partials != nil {
+		msg = a.partials.add(msg)
+		if msg == nil {
+			return []*multiline.Event{}
+		}
+	}
+	return a.bufferLine(msg)
+}
+
+func (a *LogstashAdapter) bufferLine(msg *router.Message) []*multiline.Event {
+	key := msg.Container.ID + msg.Source
+	buf := a.lookupBuffer(msg)
+	if buf == nil {
+		// ship the line on its own rather than dropping it
+		return []*multiline.Event{{Message: msg, Lines: 1, Bytes: len(msg.Data)}}
+	}
+	lines, bytes := buf.PendingSize(), buf.PendingBytes()
+	msgOrNil := buf.Buffer(msg)
+	a.trackPending(buf.PendingSize() - lines, buf.PendingBytes() - bytes)
+	a.scheduleExpiry(key, buf, msg)
 
-	if msgOrNil == nil {
-		return []*router.Message{}
-	} else {
-		return []*router.Message{msgOrNil}
+	messages := []*multiline.Event{}
+	if msgOrNil != nil {
+		messages = append(messages, msgOrNil)
 	}
+	return append(messages, a.enforcePendingBytes()...)
 }
 
-func (a *LogstashAdapter) expireCache(t time.Time) []*router.Message {
-	var messages []*router.Message
-	var linesCounter int64 = 0
+// enforcePendingBytes flushes the groups started longest ago until the lines
+// pending across all buffers fit in max_pending_bytes.
+func (a *LogstashAdapter) enforcePendingBytes() []*multiline.Event {
+	var messages []*multiline.Event
+	for a.maxPendingBytes > 0 && a.pendingBytes > a.maxPendingBytes {
+		key, ok := a.ages.first()
+		if !ok {
+			break
+		}
+		buf := a.cache[key]
+		lines, bytes := buf.PendingSize(), buf.PendingBytes()
+		msg := buf.Flush()
+		a.trackPending(-lines, -bytes)
+		a.dropBuffer(key, msg.Container.ID, msg.Source)
+		messages = append(messages, msg)
+	}
+	return messages
+}
 
-	for id, buf := range a.cache {
-		linesCounter += int64(buf.PendingSize())
-		msg := buf.Expire(t, a.cacheTTL)
-		if msg != nil {
-			messages = append(messages, msg)
-			delete(a.cache, id)
+func (a *LogstashAdapter) expireCache(t time.Time) []*multiline.Event {
+	var messages []*multiline.Event
+
+	if a.partials != nil {
+		for _, msg := range a.partials.expire(t) {
+			messages = append(messages, a.bufferLine(msg)...)
 		}
 	}
 
-	a.cachedLines.Update(linesCounter)
+	for _, id := range a.removed.popExpired(t) {
+		if a.streams[id] != nil {
+			// forgotten once its late lines are flushed
+			a.removed.set(id, t.Add(a.cacheTTL))
+		}
+	}
+
+	for _, key := range a.deadlines.popExpired(t) {
+		buf := a.cache[key]
+		msg := buf.Expire(t, a.cacheTTL)
+		if msg == nil {
+			// touched since it was scheduled
+			if deadline, ok := buf.Deadline(a.cacheTTL); ok {
+				a.deadlines.set(key, deadline)
+			}
+			continue
+		}
+		messages = append(messages, msg)
+		a.trackPending(-buf.PendingSize(), -buf.PendingBytes())
+		a.ages.remove(key)
+		delete(a.cache, key)
+		a.removeStream(msg.Container.ID, msg.Source)
+	}
 
 	return messages
 }
 
-func (a *LogstashAdapter) flushPendingMessages() []*router.Message {
-	var messages []*router.Message
+// scheduleExpiry updates the deadline of the buffer stored under key after
+// msg was added to it, dropping the buffer once nothing is pending in it.
+func (a *LogstashAdapter) scheduleExpiry(key string, buf *multiline.MultiLine, msg *router.Message) {
+	deadline, ok := buf.Deadline(a.cacheTTL)
+	if ok {
+		a.deadlines.set(key, deadline)
+		a.ages.set(key, buf.FirstTouched)
+		return
+	}
+	a.dropBuffer(key, msg.Container.ID, msg.Source)
+}
+
+// dropBuffer forgets the empty buffer stored under key.
+func (a *LogstashAdapter) dropBuffer(key, id, source string) {
+	a.deadlines.remove(key)
+	a.ages.remove(key)
+	delete(a.cache, key)
+	a.removeStream(id, source)
+}
+
+// trackPending updates the lines and bytes pending across all buffers.
+func (a *LogstashAdapter) trackPending(lines, bytes int) {
+	if lines == 0 && bytes == 0 {
+		return
+	}
+	a.pendingLines += int64(lines)
+	a.pendingBytes += int64(bytes)
+	a.cachedLines.Update(a.pendingLines)
+	a.cachedBytes.Update(a.pendingBytes)
+}
+
+func (a *LogstashAdapter) flushPendingMessages() []*multiline.Event {
+	var messages []*multiline.Event
+
+	if a.partials != nil {
+		for _, msg := range a.partials.flush() {
+			messages = append(messages, a.bufferLine(msg)...)
+		}
+	}
 
 	for _, buf := range a.cache {
 		msg := buf.Flush()
@@ -242,16 +527,22 @@ func (a *LogstashAdapter) flushPendingMessages() []*router.Message {
 	return messages
 }
 
-func (a *LogstashAdapter) sendMessages(msgs []*router.Message) {
+func (a *LogstashAdapter) sendMessages(msgs []*multiline.Event) {
+	sent := 0
 	for _, msg := range msgs {
-		if err := a.sendMessage(msg); err != nil {
+		err := a.sendMessage(msg)
+		if err == errFiltered {
+			continue
+		}
+		if err != nil {
 			log.Fatal("logstash:", err)
 		}
+		sent++
 	}
-	logMeter.Mark(int64(len(msgs)))
+	logMeter.Mark(int64(sent))
 }
 
-func (a *LogstashAdapter) sendMessage(msg *router.Message) error {
+func (a *LogstashAdapter) sendMessage(msg *multiline.Event) error {
 	buff, err := a.serialize(msg)
 
 	if err != nil {
@@ -265,46 +556,131 @@ func (a *LogstashAdapter) sendMessage(msg *router.Message) error {
 	return nil
 }
 
-func (a *LogstashAdapter) serialize(msg *router.Message) ([]byte, error) {
+func (a *LogstashAdapter) serialize(msg *multiline.Event) ([]byte, error) {
 	var js []byte
-	var jsonMsg map[string]interface{}
 
-	dockerInfo := DockerInfo{
-		Name:     msg.Container.Name,
-		ID:       msg.Container.ID,
-		Image:    msg.Container.Config.Image,
-		Hostname: msg.Container.Config.Hostname,
+	dockerInfo := a.dockerInfo(msg.Container)
+	swarm := swarmInfo(msg.Container)
+	kubernetes := kubernetesInfo(msg.Container)
+	var host *HostInfo
+	if a.host != nil {
+		host = a.host.info()
 	}
 	componentInfo := ComponentInfo{
-		Name:    msg.Container.Config.Labels["com.docker.compose.service"],
+		Name:    serviceName(msg.Container),
 		Version: msg.Container.Config.Labels["com.mm.version"],
 		Env:     msg.Container.Config.Labels["com.mm.env"],
 	}
 
-	javaLog, parsedMsg := a.parseJavaMsg(&msg.Data)
-	err := json.Unmarshal([]byte(msg.Data), &jsonMsg)
+	options := a.optionsFor(msg.Container)
+	var jsonMsg *jsonObject
+	err := errTextFormat
+	parseMsg := msg.Message
+	if options.format == formatJSON {
+		jsonMsg, err = decodeJSONObject([]byte(msg.Data))
+	}
+	if err != nil && err != errTextFormat && a.mixedJSON {
+		// a textual prefix followed by a JSON object: parse the prefix and
+		// ship the object fields
+		if prefix, obj, ok := splitJSONSuffix(msg.Data); ok {
+			jsonMsg, err = obj, nil
+			prefixMsg := *msg.Message
+			prefixMsg.Data = prefix
+			parseMsg = &prefixMsg
+		}
+	}
+	parsed := a.parse(parseMsg, options.parsers)
+	extra := a.extraFields(msg.Container)
+	level, severity := a.levels.normalize(msg.Message, &parsed, jsonMsg)
+	if options.minSeverity > 0 &&
+		!a.threshold.keep(msg.Container, options.minSeverity, a.levels.explicit(&parsed, jsonMsg)) {
+		return nil, errFiltered
+	}
+	var multilineInfo *MultilineInfo
+	if msg.Truncated || msg.Rule != "" {
+		multilineInfo = &MultilineInfo{
+			Truncated: msg.Truncated,
+			Lines:     msg.Lines,
+			Bytes:     msg.Bytes,
+			Rule:      msg.Rule,
+		}
+	}
 	if err != nil {
 		// the message is not in JSON make a new JSON message
 		msgToSend := LogstashMessage{
-			Message: *parsedMsg,
+			Message: parsed.Message,
 			Docker:  dockerInfo,
 			Component: componentInfo,
 			Stream:  msg.Source,
-			JavaLog: javaLog,
+			Swarm:   swarm,
+			Kubernetes: kubernetes,
+			Host:       host,
+			JavaLog: parsed.JavaLog,
+			Klog:    parsed.Klog,
+			Level:    level,
+			Severity: severity,
+			Multiline: multilineInfo,
+			Sequence:  msg.Sequence,
 		}
 		js, err = json.Marshal(msgToSend)
 		if err != nil {
 			return nil, err
 		}
+		if len(extra) > 0 || options.fields != nil || a.filter != nil || a.redactor != nil {
+			obj, err := decodeJSONObject(js)
+			if err != nil {
+				return nil, err
+			}
+			if err := a.finish(obj, msg.Message, extra, options, host != nil); err != nil {
+				return nil, err
+			}
+			js, err = json.Marshal(obj)
+			if err != nil {
+				return nil, err
+			}
+		}
 
 	} else {
 		// the message is already in JSON just add the docker specific fields as a nested structure
-		jsonMsg["docker"] = dockerInfo
-		if (javaLog != nil) {
-			jsonMsg["javaLog"] = javaLog
+		if a.embeddedJSON != nil {
+			a.embeddedJSON.decode(jsonMsg)
+		}
+		jsonMsg.Set("docker", dockerInfo)
+		if swarm != nil {
+			jsonMsg.Set("swarm", swarm)
+		}
+		if kubernetes != nil {
+			jsonMsg.Set("kubernetes", kubernetes)
+		}
+		hostAdded := false
+		if _, ok := jsonMsg.Get("host"); !ok && host != nil {
+			// applications often log a host of their own
+			jsonMsg.Set("host", host)
+			hostAdded = true
+		}
+		if (parsed.JavaLog != nil) {
+			jsonMsg.Set("javaLog", parsed.JavaLog)
+		}
+		if parsed.Klog != nil {
+			jsonMsg.Set("klog", parsed.Klog)
+		}
+		if level != "" {
+			jsonMsg.Set("level", level)
+			jsonMsg.Set("severity", severity)
+		}
+		if multilineInfo != nil {
+			jsonMsg.Set("multiline", multilineInfo)
+		}
+		if msg.Sequence > 0 {
+			jsonMsg.Set("sequence", msg.Sequence)
+		}
+		jsonMsg.Set("component", componentInfo)
+		if _, ok := jsonMsg.Get("message"); !ok || (parsed.Matched && parsed.Message != "") {
+			jsonMsg.Set("message", parsed.Message)
+		}
+		if err := a.finish(jsonMsg, msg.Message, extra, options, hostAdded); err != nil {
+			return nil, err
 		}
-		jsonMsg["component"] = componentInfo
-		jsonMsg["message"] = *parsedMsg
 		js, err = json.Marshal(jsonMsg)
 		if err != nil {
 			return nil, err
@@ -314,6 +690,72 @@ func (a *LogstashAdapter) serialize(msg *router.Message) ([]byte, error) {
 	return js, nil
 }
 
+// finish adds the optional sections to the event document obj, then runs it
+// through the filters and the redactor. hostAdded tells whether the host key
+// of obj is the host section rather than a field of the application.
+func (a *LogstashAdapter) finish(obj *jsonObject, msg *router.Message, extra []extraField, options *containerOptions, hostAdded bool) error {
+	decorate(obj, extra, options.fields)
+	if a.filter != nil && !a.filter.keep(obj, msg) {
+		return errFiltered
+	}
+	if a.redactor != nil {
+		a.redactor.redactDocument(obj, hostAdded)
+	}
+	return nil
+}
+
+// parsedLog holds the fields extracted from a message by the first parser
+// that recognised it.
+type parsedLog struct {
+	Matched bool
+	Message string
+	JavaLog *JavaLog
+	Klog    *KlogLog
+}
+
+// parserFn fills parsed from msg and reports whether msg was recognised.
+type parserFn func(a *LogstashAdapter, msg *router.Message, parsed *parsedLog) bool
+
+var parserTypes = map[string]parserFn{
+	"java": parseJava,
+	"klog": parseKlog,
+}
+
+func lookupParsers(names string) []parserFn {
+	var parsers []parserFn
+	for _, name := range splitList(names) {
+		parser, ok := parserTypes[name]
+		if !ok {
+			log.Println("logstash: unknown parser:", name)
+			continue
+		}
+		parsers = append(parsers, parser)
+	}
+	return parsers
+}
+
+// parse runs msg through parsers, stopping at the first match.
+func (a *LogstashAdapter) parse(msg *router.Message, parsers []parserFn) parsedLog {
+	parsed := parsedLog{Message: msg.Data}
+	for _, parser := range parsers {
+		if parser(a, msg, &parsed) {
+			parsed.Matched = true
+			break
+		}
+	}
+	return parsed
+}
+
+func parseJava(a *LogstashAdapter, msg *router.Message, parsed *parsedLog) bool {
+	javaLog, parsedMsg := a.parseJavaMsg(&msg.Data)
+	if javaLog == nil {
+		return false
+	}
+	parsed.JavaLog = javaLog
+	parsed.Message = *parsedMsg
+	return true
+}
+
 func (a *LogstashAdapter) parseJavaMsg(msg *string) (*JavaLog, *string) {
 	var cleanMsg = a.cleanupRegExp.ReplaceAllLiteralString(*msg, "")
 	match := a.javaLogRegExp.FindStringSubmatch(cleanMsg)
@@ -365,6 +807,16 @@ type DockerInfo struct {
 	ID       string `json:"id"`
 	Image    string `json:"image"`
 	Hostname string `json:"hostname"`
+
+	ImageID        string     `json:"imageId,omitempty"`
+	ImageDigest    string     `json:"imageDigest,omitempty"`
+	Registry       string     `json:"registry,omitempty"`
+	Repository     string     `json:"repository,omitempty"`
+	Tag            string     `json:"tag,omitempty"`
+	Created        *time.Time `json:"created,omitempty"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	RestartCount   *int       `json:"restartCount,omitempty"`
+	ComposeProject string     `json:"composeProject,omitempty"`
 }
 
 type ComponentInfo struct {
@@ -391,13 +843,31 @@ type JavaException struct {
 	Jar            string `json:"jar"`
 }
 
+// MultilineInfo describes a multi-line event that was cut short by the
+// max_lines or max_bytes limits, or grouped by one of the group_with=auto
+// rules.
+type MultilineInfo struct {
+	Truncated bool   `json:"truncated"`
+	Lines     int    `json:"lines"`
+	Bytes     int    `json:"bytes"`
+	Rule      string `json:"rule,omitempty"`
+}
+
 // LogstashMessage is a simple JSON input to Logstash.
 type LogstashMessage struct {
 	Message   string      `json:"message"`
 	Stream    string      `json:"stream"`
 	Docker    DockerInfo  `json:"docker"`
 	Component ComponentInfo `json:"component"`
+	Swarm     *SwarmInfo `json:"swarm,omitempty"`
+	Kubernetes *KubernetesInfo `json:"kubernetes,omitempty"`
+	Host       *HostInfo       `json:"host,omitempty"`
 	JavaLog   *JavaLog `json:"javaLog,omitempty"`
+	Klog      *KlogLog `json:"klog,omitempty"`
+	Level     string   `json:"level,omitempty"`
+	Severity  int      `json:"severity,omitempty"`
+	Multiline *MultilineInfo `json:"multiline,omitempty"`
+	Sequence  uint64         `json:"sequence,omitempty"`
 }
 
 // writers
@@ -23,6 +23,16 @@ func makeMockWriter() (writer, *[]string) {
 	}, results
 }
 
+// makeChanWriter returns a writer sending what is written to a channel, for
+// tests reading output while the adapter is still streaming.
+func makeChanWriter() (writer, chan string) {
+	written := make(chan string, 16)
+	return func(b []byte) (int, error) {
+		written <- string(b)
+		return len(b), nil
+	}, written
+}
+
 func TestStreamMultiline(t *testing.T) {
 	assert := assert.New(t)
 
@@ -80,6 +90,63 @@ func TestStreamMultilineStacktrace(t *testing.T) {
 }
 
 
+func TestStreamMultilineTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"max_lines": "2"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	lines := []string{
+		"Line1",
+		"   Line1.1",
+		"   Line1.2",
+	}
+
+	go pump(logstream, &container, [][]string{lines})
+
+	adapter.Stream(logstream)
+	data := parseResult(assert, (*results)[0])
+
+	assert.Equal(strings.Join(lines[:2], "\n"), data["message"])
+	assert.Equal(map[string]interface{}{
+		"truncated": true,
+		"lines":     float64(3),
+		"bytes":     float64(27),
+	}, data["multiline"])
+}
+
+func TestStreamMultilineAuto(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"group_with": "auto"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	lines := []string{
+		"Traceback (most recent call last):",
+		`  File "app.py", line 1, in <module>`,
+		"ZeroDivisionError: division by zero",
+	}
+
+	go pump(logstream, &container, [][]string{lines})
+
+	adapter.Stream(logstream)
+	data := parseResult(assert, (*results)[0])
+
+	assert.Equal(strings.Join(lines, "\n"), data["message"])
+	multiline := data["multiline"].(map[string]interface{})
+	assert.Equal("python", multiline["rule"])
+	assert.Equal(false, multiline["truncated"])
+	assert.Equal(float64(3), multiline["lines"])
+}
+
 func TestStreamJson(t *testing.T) {
 	assert := assert.New(t)
 	mockWriter, results := makeMockWriter()
@@ -170,6 +237,67 @@ func TestCacheExpiration(t *testing.T) {
 	close(logstream)
 }
 
+func TestCacheExpirationIdleTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	chanWriter, written := makeChanWriter()
+	var r router.Route
+	r.Options = map[string]string{"cache_ttl": "1h", "idle_timeout": "5ms"}
+	adapter := newLogstashAdapter(&r, chanWriter)
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	done := make(chan bool)
+	go func() {
+		adapter.Stream(logstream)
+		close(done)
+	}()
+
+	msg := makeDummyMessage(&container, "test")
+	logstream <- &msg
+
+	select {
+	case result := <-written:
+		data := parseResult(assert, result)
+		assert.Equal("test", data["message"])
+	case <-time.After(time.Second):
+		assert.Fail("buffers must be flushed at their own deadline")
+	}
+
+	close(logstream)
+	<-done
+	assert.Empty(adapter.deadlines.entries)
+}
+
+func TestMaxPendingBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	var r router.Route
+	r.Options = map[string]string{"max_pending_bytes": "30"}
+	adapter := newLogstashAdapter(&r, nil)
+	first := makeDummyContainer("first")
+	second := makeDummyContainer("second")
+
+	for _, msg := range []router.Message{
+		{Container: &first, Source: "stderr", Data: "first error"},
+		{Container: &first, Source: "stderr", Data: "  at frame"},
+	} {
+		msg := msg
+		assert.Empty(adapter.bufferMessage(&msg))
+	}
+	assert.Equal(int64(22), adapter.pendingBytes)
+	assert.Equal(int64(22), adapter.cachedBytes.Value())
+
+	// the group started first is flushed to make room
+	msg := router.Message{Container: &second, Source: "stderr", Data: "second error"}
+	events := adapter.bufferMessage(&msg)
+	assert.Equal(1, len(events))
+	assert.Equal("first error\n  at frame", events[0].Data)
+	assert.Equal(int64(12), adapter.pendingBytes)
+	assert.Equal(int64(1), adapter.cachedLines.Value())
+	assert.Equal(1, len(adapter.cache))
+}
+
 func TestTCPInit(t *testing.T) {
 	assert := assert.New(t)
 	l, err := net.Listen("tcp", "localhost:0")
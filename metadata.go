@@ -0,0 +1,153 @@
+package logstash
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const (
+	defaultMetadataKey = "metadata"
+	defaultMaskEnv     = "*PASSWORD*,*PASSWD*,*SECRET*,*TOKEN*,*KEY*,*CREDENTIAL*,*AUTH*"
+	maskedValue        = "********"
+)
+
+// metadataExporter selects the container labels and environment variables
+// shipped with every event, under one configurable key:
+//
+//	include_labels labels to export, as comma separated globs
+//	exclude_labels labels never to export, even if included
+//	include_env    environment variables to export
+//	mask_env       environment variables whose values are masked, matched
+//	               case-insensitively; mask_env= masks none
+//	metadata_key   field holding the labels and env sections
+type metadataExporter struct {
+	key           string
+	includeLabels []string
+	excludeLabels []string
+	includeEnv    []string
+	maskEnv       []string
+	containers    map[string]*containerMetadata
+}
+
+type containerMetadata struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+}
+
+// newMetadataExporter returns nil when no labels or environment variables
+// are to be exported.
+func newMetadataExporter(options map[string]string) *metadataExporter {
+	includeLabels := globList(options["include_labels"])
+	includeEnv := globList(options["include_env"])
+	if len(includeLabels) == 0 && len(includeEnv) == 0 {
+		return nil
+	}
+
+	key, ok := options["metadata_key"]
+	if !ok || key == "" {
+		key = defaultMetadataKey
+	}
+
+	maskEnv, ok := options["mask_env"]
+	if !ok {
+		maskEnv = defaultMaskEnv
+	}
+
+	return &metadataExporter{
+		key:           key,
+		includeLabels: includeLabels,
+		excludeLabels: globList(options["exclude_labels"]),
+		includeEnv:    includeEnv,
+		maskEnv:       globList(strings.ToUpper(maskEnv)),
+		containers:    make(map[string]*containerMetadata),
+	}
+}
+
+// metadata returns the exported labels and environment of container, or nil
+// if there are none. Results are cached per container ID.
+func (m *metadataExporter) metadata(container *docker.Container) *containerMetadata {
+	if metadata, ok := m.containers[container.ID]; ok {
+		return metadata
+	}
+
+	metadata := &containerMetadata{}
+	for name, value := range containerLabels(container) {
+		if matchGlobs(m.includeLabels, name) && !matchGlobs(m.excludeLabels, name) {
+			if metadata.Labels == nil {
+				metadata.Labels = make(map[string]string)
+			}
+			metadata.Labels[name] = value
+		}
+	}
+
+	if container.Config != nil && len(m.includeEnv) > 0 {
+		for _, variable := range container.Config.Env {
+			parts := strings.SplitN(variable, "=", 2)
+			if len(parts) != 2 || !matchGlobs(m.includeEnv, parts[0]) {
+				continue
+			}
+			value := parts[1]
+			if matchGlobs(m.maskEnv, strings.ToUpper(parts[0])) {
+				value = maskedValue
+			}
+			if metadata.Env == nil {
+				metadata.Env = make(map[string]string)
+			}
+			metadata.Env[parts[0]] = value
+		}
+	}
+
+	if metadata.Labels == nil && metadata.Env == nil {
+		metadata = nil
+	}
+	m.containers[container.ID] = metadata
+	return metadata
+}
+
+func (m *metadataExporter) forget(id string) {
+	delete(m.containers, id)
+}
+
+// extraField is a top-level field added to every event next to the docker
+// and component sections.
+type extraField struct {
+	key   string
+	value interface{}
+}
+
+// extraFields returns the optional sections configured for the route.
+func (a *LogstashAdapter) extraFields(container *docker.Container) []extraField {
+	var fields []extraField
+	if a.metadata != nil {
+		if metadata := a.metadata.metadata(container); metadata != nil {
+			fields = append(fields, extraField{a.metadata.key, metadata})
+		}
+	}
+	return fields
+}
+
+// globList parses a comma separated list of path.Match patterns, dropping
+// the invalid ones.
+func globList(value string) []string {
+	var globs []string
+	for _, glob := range splitList(value) {
+		if _, err := path.Match(glob, ""); err != nil {
+			log.Println("logstash: invalid pattern:", glob)
+			continue
+		}
+		globs = append(globs, glob)
+	}
+	return globs
+}
+
+func matchGlobs(globs []string, name string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
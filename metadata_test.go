@@ -0,0 +1,83 @@
+package logstash
+
+import (
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{
+		"include_labels": "com.example.*,team",
+		"exclude_labels": "com.example.internal.*",
+		"include_env":    "APP_*,db_password",
+	}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	container.Config.Labels = map[string]string{
+		"com.example.owner":        "payments",
+		"com.example.internal.ref": "x",
+		"team":                     "core",
+		"other":                    "ignored",
+	}
+	container.Config.Env = []string{
+		"APP_MODE=production",
+		"APP_API_TOKEN=abc",
+		"db_password=hunter2",
+		"PATH=/usr/bin",
+	}
+
+	go pump(logstream, &container, [][]string{{"plain"}, {`{"message": "json"}`}})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+
+	for _, result := range *results {
+		data := parseResult(assert, result)
+		assert.Equal(map[string]interface{}{
+			"labels": map[string]interface{}{
+				"com.example.owner": "payments",
+				"team":              "core",
+			},
+			"env": map[string]interface{}{
+				"APP_MODE":      "production",
+				"APP_API_TOKEN": maskedValue,
+				"db_password":   maskedValue,
+			},
+		}, data["metadata"])
+	}
+}
+
+func TestMetadataExporterOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(newMetadataExporter(map[string]string{}), "nothing is exported by default")
+
+	exporter := newMetadataExporter(map[string]string{
+		"include_env":  "*",
+		"mask_env":     "",
+		"metadata_key": "container",
+	})
+	assert.Equal("container", exporter.key)
+
+	container := makeDummyContainer("anid")
+	assert.Nil(exporter.metadata(&container))
+
+	container = makeDummyContainer("secret")
+	container.Config.Env = []string{"API_KEY=abc", "EMPTY="}
+	metadata := exporter.metadata(&container)
+	assert.Equal(map[string]string{"API_KEY": "abc", "EMPTY": ""}, metadata.Env)
+	assert.True(metadata == exporter.metadata(&container), "metadata is cached per container")
+
+	exporter.forget("secret")
+	assert.NotContains(exporter.containers, "secret")
+
+	assert.Equal([]string{"a*"}, globList("a*, [b"))
+}
@@ -0,0 +1,92 @@
+package logstash
+
+import (
+	"log"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/rcrowley/go-metrics"
+)
+
+const minLevelLabel = "logstash.min_level"
+
+// levelThreshold drops events below a minimum level, set with the min_level
+// route option or the logstash.min_level container label, e.g. min_level=warn.
+// Only the level an event states itself, through a parser or a JSON level
+// field, is compared; events without one are kept unless
+// min_level_missing=drop. Drops are counted per container in
+// <route>_min_level_dropped.<container name> counters.
+type levelThreshold struct {
+	routeID     string
+	dropMissing bool
+	dropped     map[string]*levelDrops
+}
+
+// levelDrops counts the events dropped from one container.
+type levelDrops struct {
+	name    string
+	counter metrics.Counter
+}
+
+func newLevelThreshold(routeID string, options map[string]string) *levelThreshold {
+	policy := options["min_level_missing"]
+	if policy != "" && policy != "keep" && policy != "drop" {
+		log.Println("logstash: unknown min_level_missing policy:", policy)
+	}
+	return &levelThreshold{
+		routeID:     routeID,
+		dropMissing: policy == "drop",
+		dropped:     make(map[string]*levelDrops),
+	}
+}
+
+// minSeverity returns the severity of the level named value, or 0 to ship all
+// levels.
+func (n *levelNormalizer) minSeverity(value string) int {
+	if value == "" {
+		return 0
+	}
+	level := n.lookup(value)
+	if level == "" {
+		log.Println("logstash: unknown min_level:", value)
+		return 0
+	}
+	return levelSeverities[level]
+}
+
+// keep reports whether an event of container with the given explicit level
+// reaches minSeverity, counting the events it drops.
+func (t *levelThreshold) keep(container *docker.Container, minSeverity int, level string) bool {
+	if minSeverity == 0 {
+		return true
+	}
+	if level == "" && !t.dropMissing {
+		return true
+	}
+	if level != "" && levelSeverities[level] >= minSeverity {
+		return true
+	}
+	t.counter(container).Inc(1)
+	return false
+}
+
+func (t *levelThreshold) counter(container *docker.Container) metrics.Counter {
+	drops, ok := t.dropped[container.ID]
+	if !ok {
+		drops = &levelDrops{
+			name:    t.routeID + "_min_level_dropped." + strings.TrimPrefix(container.Name, "/"),
+			counter: metrics.NewCounter(),
+		}
+		metrics.Register(drops.name, drops.counter)
+		t.dropped[container.ID] = drops
+	}
+	return drops.counter
+}
+
+// forget unregisters the counter of a container that was removed.
+func (t *levelThreshold) forget(id string) {
+	if drops, ok := t.dropped[id]; ok {
+		metrics.Unregister(drops.name)
+		delete(t.dropped, id)
+	}
+}
@@ -0,0 +1,77 @@
+package logstash
+
+import (
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamMinLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"min_level": "warning"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{
+		{"12:00:00.000[INFO ][uuid][main][Logger]: noisy"},
+		{"12:00:00.000[WARN ][uuid][main][Logger]: careful"},
+		{`{"message": "debug json", "level": "debug"}`},
+		{`{"message": "error json", "level": 50}`},
+		{"no level"},
+	})
+
+	adapter.Stream(logstream)
+
+	var messages []interface{}
+	for _, result := range *results {
+		messages = append(messages, parseResult(assert, result)["message"])
+	}
+	assert.Equal([]interface{}{"careful", "error json", "no level"}, messages)
+	assert.Equal(int64(2), adapter.threshold.dropped["anid"].counter.Count())
+
+	adapter.removeContainer("anid")
+	adapter.forgetStoppedContainers()
+	assert.NotContains(adapter.threshold.dropped, "anid")
+}
+
+func TestMinLevelLabelAndMissingPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"min_level_missing": "drop"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+	assert.Equal(0, adapter.minSeverity)
+
+	logstream := make(chan *router.Message)
+	noisy := makeDummyContainer("noisy")
+	noisy.Config.Labels = map[string]string{"logstash.min_level": "error"}
+	quiet := makeDummyContainer("quiet")
+
+	go func() {
+		for _, msg := range []*router.Message{
+			{Container: &noisy, Source: "stdout", Data: `{"message": "warn", "level": "warn"}`},
+			{Container: &noisy, Source: "stderr", Data: "stderr without level"},
+			{Container: &noisy, Source: "stdout", Data: `{"message": "fatal", "level": "FATAL"}`},
+			{Container: &quiet, Source: "stdout", Data: "no threshold"},
+		} {
+			logstream <- msg
+		}
+		close(logstream)
+	}()
+
+	adapter.Stream(logstream)
+
+	var messages []interface{}
+	for _, result := range *results {
+		messages = append(messages, parseResult(assert, result)["message"])
+	}
+	assert.ElementsMatch([]interface{}{"fatal", "no threshold"}, messages,
+		"stream levels do not count as the level of an event")
+}
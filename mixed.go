@@ -0,0 +1,70 @@
+package logstash
+
+import (
+	"strings"
+)
+
+// splitJSONSuffix splits lines such as `2026-10-16 12:00:00 INFO {"user":"x"}`
+// into the text before the JSON object and the decoded object. ok is false
+// unless the line ends with a JSON object preceded by a non-empty prefix.
+// The object is found by matching its closing brace from the end of the line,
+// so it is decoded at most once however many braces the prefix holds, and it
+// must start a whitespace separated token.
+func splitJSONSuffix(data string) (prefix string, obj *jsonObject, ok bool) {
+	trimmed := strings.TrimRight(data, " \t\r\n")
+	if !strings.HasSuffix(trimmed, "}") {
+		return "", nil, false
+	}
+
+	start := openingBrace(trimmed)
+	if start < 1 || (trimmed[start-1] != ' ' && trimmed[start-1] != '\t') {
+		return "", nil, false
+	}
+	prefix = strings.TrimRight(trimmed[:start], " \t")
+	if prefix == "" {
+		return "", nil, false
+	}
+	obj, err := decodeJSONObject([]byte(trimmed[start:]))
+	if err != nil {
+		return "", nil, false
+	}
+	return prefix, obj, true
+}
+
+// openingBrace returns the index of the '{' matching the '}' that ends s,
+// skipping braces inside JSON strings, or -1.
+func openingBrace(s string) int {
+	depth := 0
+	inString := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if inString {
+			if c == '"' && !escapedAt(s, i) {
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '}':
+			depth++
+		case '{':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// escapedAt reports whether the character at i is escaped by the backslashes
+// before it.
+func escapedAt(s string, i int) bool {
+	backslashes := 0
+	for i--; i >= 0 && s[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
+}
@@ -0,0 +1,95 @@
+package logstash
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitJSONSuffix(t *testing.T) {
+	prefix, obj, ok := splitJSONSuffix(`2026-10-16 12:00:00 INFO {"user":"x","meta":{"a":1}}`)
+	assert.True(t, ok)
+	assert.Equal(t, "2026-10-16 12:00:00 INFO", prefix)
+	encoded, _ := json.Marshal(obj)
+	assert.Equal(t, `{"user":"x","meta":{"a":1}}`, string(encoded))
+
+	prefix, _, ok = splitJSONSuffix(`got {bad} and {"ok":true}`)
+	assert.True(t, ok)
+	assert.Equal(t, "got {bad} and", prefix)
+
+	prefix, obj, ok = splitJSONSuffix(`say "{" then {"text":"a } \" { b\\","n": {"x": 1}}`)
+	assert.True(t, ok, "braces in strings are skipped")
+	assert.Equal(t, `say "{" then`, prefix)
+	encoded, _ = json.Marshal(obj)
+	assert.Equal(t, `{"text":"a } \" { b\\","n":{"x":1}}`, string(encoded))
+
+	prefix, _, ok = splitJSONSuffix(strings.Repeat("code {x} ", 10000) + `{"ok":true}`)
+	assert.True(t, ok)
+	assert.Equal(t, strings.TrimSpace(strings.Repeat("code {x} ", 10000)), prefix)
+
+	for _, line := range []string{
+		`no json here`,
+		`{"only":"json"}`,
+		`prefix {"unterminated":`,
+		`prefix {"a":1} trailing`,
+		`prefix ["array"]`,
+		`prefix:{"glued":true}`,
+		`java.util.HashMap {a={b=1}, c={d=2}}`,
+	} {
+		_, _, ok = splitJSONSuffix(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestStreamMixedJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+
+	go pump(logstream, &container, [][]string{
+		{`2026-10-16 12:00:00 INFO {"user":"x","action":"login"}`},
+		{`12:55:46.650[WARN ][6d3b36a5][main]o.e.Foo : {"user":"y","message":"denied"}`},
+	})
+
+	adapter.Stream(logstream)
+
+	data := parseResult(assert, (*results)[0])
+	assert.Equal("2026-10-16 12:00:00 INFO", data["message"])
+	assert.Equal("x", data["user"])
+	assert.Equal("login", data["action"])
+	assertDockerInfo(assert, &container, data["docker"])
+
+	data = parseResult(assert, (*results)[1])
+	assert.Equal("denied", data["message"])
+	assert.Equal("y", data["user"])
+	assert.Equal("warn", data["level"])
+	assert.Equal("WARN", data["javaLog"].(map[string]interface{})["level"])
+}
+
+func TestStreamMixedJSONDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"mixed_json": "false"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	line := `2026-10-16 12:00:00 INFO {"user":"x"}`
+
+	go pump(logstream, &container, [][]string{{line}})
+
+	adapter.Stream(logstream)
+
+	data := parseResult(assert, (*results)[0])
+	assert.Equal(line, data["message"])
+	assert.Nil(data["user"])
+}
@@ -0,0 +1,85 @@
+package multiline
+
+import (
+	"regexp"
+)
+
+// autoRule recognises the continuation lines of one kind of multi-line
+// output, such as a Java stack trace.
+type autoRule struct {
+	name      string
+	continues func(lastText, currentText string) bool
+}
+
+// anyMatch returns a predicate matching currentText against any of patterns.
+func anyMatch(patterns ...string) func(lastText, currentText string) bool {
+	var regexps []*regexp.Regexp
+	for _, pattern := range patterns {
+		regexps = append(regexps, regexp.MustCompile(pattern))
+	}
+	return func(lastText, currentText string) bool {
+		for _, re := range regexps {
+			if re.MatchString(currentText) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+var (
+	pythonFrame     = regexp.MustCompile(`^\s+\S`)
+	pythonException = regexp.MustCompile(`^[A-Za-z_][\w.]*(Error|Exception|Exit|Interrupt|Warning)(: .*)?$`)
+	goPanic         = regexp.MustCompile(`^(panic: |fatal error: )`)
+	goFrameFile     = regexp.MustCompile(`^\t.*:\d+( \+0x[0-9a-f]+)?$`)
+	goContinuation  = regexp.MustCompile(
+		`^(\t|goroutine \d+ \[.*\]:$|created by |[\w\-./]+\.[\w.()*\[\]]+\(.*\)$)`)
+)
+
+// autoRules are tried in order, the more specific rules first.
+var autoRules = []autoRule{
+	{"dotnet", anyMatch(
+		`^\s*---> `,
+		`^\s+at .+ in .+:line \d+$`,
+		`^\s*--- End of (inner exception stack trace|stack trace from previous location)`,
+	)},
+	{"node", anyMatch(
+		`^\s+at .*:\d+:\d+\)?$`,
+	)},
+	{"java", anyMatch(
+		`^\s+at [\w$.<>/]+\(.*\)`,
+		`^\s*Caused by: `,
+		`^\s*Suppressed: `,
+		`^\s+\.\.\. \d+ (more|common frames omitted)`,
+	)},
+	{"python", func(lastText, currentText string) bool {
+		if pythonFrame.MatchString(currentText) {
+			return pythonFrame.MatchString(lastText) ||
+				lastText == "Traceback (most recent call last):"
+		}
+		// the exception ends the traceback, following the indented frames
+		return pythonFrame.MatchString(lastText) && pythonException.MatchString(currentText)
+	}},
+	{"go", func(lastText, currentText string) bool {
+		if currentText == "" {
+			// blank lines follow the panic message and separate goroutines
+			return goPanic.MatchString(lastText) || goFrameFile.MatchString(lastText)
+		}
+		return goContinuation.MatchString(currentText)
+	}},
+}
+
+// matchAutoRule returns the name of the first rule that continues lastText
+// with currentText, or the empty string if none does. Once a group has been
+// recognised only its own rule, current, is tried.
+func matchAutoRule(current, lastText, currentText string) string {
+	for _, rule := range autoRules {
+		if current != "" && rule.name != current {
+			continue
+		}
+		if rule.continues(lastText, currentText) {
+			return rule.name
+		}
+	}
+	return ""
+}
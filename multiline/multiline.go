@@ -6,14 +6,20 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type MultilineConfig struct {
-	Pattern   *regexp.Regexp `config:"pattern"     validate:"required"`
-	GroupWith string         `config:"match"       validate:"required"`
-	Negate    bool           `config:"negate"`
-	Separator *string        `config:"separator"`
-	MaxLines  int            `config:"max_lines"`
+	Pattern      *regexp.Regexp `config:"pattern"     validate:"required"`
+	GroupWith    string         `config:"match"       validate:"required"`
+	Negate       bool           `config:"negate"`
+	Separator    *string        `config:"separator"`
+	MaxLines     int            `config:"max_lines"`
+	MaxBytes     int            `config:"max_bytes"`
+	StartPattern *regexp.Regexp `config:"start_pattern"`
+	EndPattern   *regexp.Regexp `config:"end_pattern"`
+	MaxDuration  time.Duration  `config:"max_duration"`
+	IdleTimeout  time.Duration  `config:"idle_timeout"`
 }
 
 // MultiLine processor combining multiple line events into one multi-line event.
@@ -21,16 +27,55 @@ type MultilineConfig struct {
 // Lines to be combined are matched by some configurable predicate using
 // regular expression.
 //
-// The maximum number of lines to be returned is fully configurable.
+// The maximum number of lines and bytes to be returned is fully configurable.
 // Even if limits are reached subsequent lines are matched, until event is
 // fully finished.
+//
+// A group is flushed once it has been idle for longer than the idle timeout,
+// or once it has been open for longer than the maximum duration, even if
+// matching lines keep arriving.
 type MultiLine struct {
 	isMultiline matcher
 	maxLines    int
+	maxBytes    int
 	separator   string
 
-	pending     []*router.Message
-	LastTouched time.Time
+	maxDuration time.Duration
+	idleTimeout time.Duration
+
+	// block mode: lines from a StartPattern match up to and including an
+	// EndPattern match form one event
+	startPattern *regexp.Regexp
+	endPattern   *regexp.Regexp
+	blockOpen    bool
+
+	// auto mode: continuation lines are recognised by the built-in rules,
+	// rule is the one that grouped the pending lines
+	auto bool
+	rule string
+
+	pending      []*router.Message
+	pendingBytes int
+	totalLines   int
+	totalBytes   int
+	truncated    bool
+	lastData     string
+	FirstTouched time.Time
+	LastTouched  time.Time
+}
+
+// Event is a group of lines flushed from a MultiLine buffer as one message.
+// Lines and Bytes describe the group as received, before any truncation.
+type Event struct {
+	*router.Message
+	Lines     int
+	Bytes     int
+	Truncated bool
+	Rule      string
+
+	// Sequence numbers the events of a container in order, when the
+	// consumer of the buffer assigns one.
+	Sequence uint64
 }
 
 const (
@@ -47,6 +92,13 @@ type matcher func(lastText, currentText string) bool
 // NewMultiLine creates a new multi-line processor combining stream of
 // line events into stream of multi-line events.
 func NewMultiLine(config *MultilineConfig) (MultiLine, error) {
+	if config.GroupWith == "block" {
+		return newBlockMultiLine(config)
+	}
+	if config.GroupWith == "auto" {
+		return newAutoMultiLine(config)
+	}
+
 	types := map[string]func(*regexp.Regexp) (matcher, error){
 		"next":     nextMatcher,
 		"previous": previousMatcher,
@@ -66,27 +118,84 @@ func NewMultiLine(config *MultilineConfig) (MultiLine, error) {
 		matcher = negatedMatcher(matcher)
 	}
 
-	maxLines := defaultMaxLines
-	if config.MaxLines > 0 {
-		maxLines = config.MaxLines
+	ml := MultiLine{
+		isMultiline: matcher,
+		separator:   configSeparator(config),
+		maxLines:    configMaxLines(config),
+		maxBytes:    config.MaxBytes,
+		maxDuration: config.MaxDuration,
+		idleTimeout: config.IdleTimeout,
 	}
+	return ml, nil
+}
 
-	separator := defaultSeparator
-	if config.Separator != nil {
-		separator = *config.Separator
+// newBlockMultiLine creates a processor grouping the lines between a start
+// and an end pattern, like BEGIN/END delimited output or XML dumps. Lines
+// outside of a block are passed on as single events.
+func newBlockMultiLine(config *MultilineConfig) (MultiLine, error) {
+	if config.StartPattern == nil || config.EndPattern == nil {
+		return MultiLine{}, fmt.Errorf("block matcher requires start and end patterns")
 	}
 
 	ml := MultiLine{
-		isMultiline: matcher,
-		separator:   separator,
-		maxLines:    maxLines,
+		separator:    configSeparator(config),
+		maxLines:     configMaxLines(config),
+		maxBytes:     config.MaxBytes,
+		maxDuration:  config.MaxDuration,
+		idleTimeout:  config.IdleTimeout,
+		startPattern: config.StartPattern,
+		endPattern:   config.EndPattern,
 	}
 	return ml, nil
 }
 
-// Adds a message to the MultiLine buffer, returning a flushed message if one is ready
-func (ml *MultiLine) Buffer(next *router.Message) *router.Message {
-	ml.LastTouched = time.Now()
+// newAutoMultiLine creates a processor recognising common stack traces and
+// dumps (Java, Python, Go, Node.js, .NET) without a configured pattern.
+func newAutoMultiLine(config *MultilineConfig) (MultiLine, error) {
+	ml := MultiLine{
+		separator:   configSeparator(config),
+		maxLines:    configMaxLines(config),
+		maxBytes:    config.MaxBytes,
+		maxDuration: config.MaxDuration,
+		idleTimeout: config.IdleTimeout,
+		auto:        true,
+	}
+	return ml, nil
+}
+
+func configMaxLines(config *MultilineConfig) int {
+	if config.MaxLines > 0 {
+		return config.MaxLines
+	}
+	return defaultMaxLines
+}
+
+func configSeparator(config *MultilineConfig) string {
+	if config.Separator != nil {
+		return *config.Separator
+	}
+	return defaultSeparator
+}
+
+// Adds a message to the MultiLine buffer, returning a flushed event if one is ready
+func (ml *MultiLine) Buffer(next *router.Message) *Event {
+	now := time.Now()
+	ml.LastTouched = now
+	if ml.isTooOld(now) {
+		// the group has been open for too long, next starts a new one
+		msg := ml.Flush()
+		ml.reset()
+		ml.blockOpen = false
+		ml.buffer(next)
+		return msg
+	}
+	return ml.buffer(next)
+}
+
+func (ml *MultiLine) buffer(next *router.Message) *Event {
+	if ml.startPattern != nil {
+		return ml.bufferBlock(next)
+	}
 	if ml.isContinuationMessage(next) {
 		return ml.addPending(next)
 	} else {
@@ -94,65 +203,193 @@ func (ml *MultiLine) Buffer(next *router.Message) *router.Message {
 	}
 }
 
+func (ml *MultiLine) bufferBlock(next *router.Message) *Event {
+	if !ml.blockOpen {
+		msg := ml.StartNewLine(next)
+		ml.blockOpen = ml.startPattern.MatchString(next.Data) &&
+			!ml.endPattern.MatchString(next.Data)
+		return msg
+	}
+
+	ml.addPending(next)
+	if !ml.endPattern.MatchString(next.Data) {
+		return nil
+	}
+
+	// the block is complete, no need to wait for the next line
+	msg := ml.Flush()
+	ml.reset()
+	ml.blockOpen = false
+	return msg
+}
+
 func (ml *MultiLine) isContinuationMessage(msg *router.Message) bool {
-	return ml.PendingSize() == 0 ||
-		ml.isMultiline(ml.getLastPendingData(), msg.Data)
+	if ml.PendingSize() == 0 {
+		return true
+	}
+	if ml.auto {
+		rule := matchAutoRule(ml.rule, ml.lastData, msg.Data)
+		if rule == "" {
+			return false
+		}
+		ml.rule = rule
+		return true
+	}
+	return ml.isMultiline(ml.lastData, msg.Data)
 }
 
-func (ml *MultiLine) addPending(next *router.Message) *router.Message {
-	if ml.PendingSize() < ml.maxLines {
-		ml.pending = append(ml.pending, next)
-	} else if ml.PendingSize() == ml.maxLines {
-		truncMessage := *next
-		truncMessage.Data = "[Truncated]"
-		ml.pending = append(ml.pending, &truncMessage)
+// addPending appends next to the pending group. Once the group exceeds
+// maxLines or maxBytes further lines are only counted, and the line crossing
+// the byte limit is cut short.
+func (ml *MultiLine) addPending(next *router.Message) *Event {
+	separatorSize := 0
+	if ml.totalLines > 0 {
+		separatorSize = len(ml.separator)
+	}
+	if ml.totalLines == 0 {
+		ml.FirstTouched = ml.LastTouched
+	}
+	ml.totalLines++
+	ml.totalBytes += separatorSize + len(next.Data)
+	ml.lastData = next.Data
+
+	if ml.truncated {
+		return nil
+	}
+
+	if ml.PendingSize() >= ml.maxLines {
+		ml.truncated = true
+		return nil
 	}
 
+	if ml.maxBytes > 0 && ml.pendingBytes+separatorSize+len(next.Data) > ml.maxBytes {
+		ml.truncated = true
+		room := ml.maxBytes - ml.pendingBytes - separatorSize
+		if room <= 0 {
+			return nil
+		}
+		cut := *next
+		cut.Data = truncateUTF8(next.Data, room)
+		next = &cut
+	}
+
+	ml.pending = append(ml.pending, next)
+	ml.pendingBytes += separatorSize + len(next.Data)
+
 	return nil
 }
 
-func (ml *MultiLine) StartNewLine(next *router.Message) *router.Message {
+func (ml *MultiLine) StartNewLine(next *router.Message) *Event {
 	msg := ml.Flush()
-	ml.pending = []*router.Message{next}
+	ml.reset()
+	ml.addPending(next)
 
 	return msg
 }
 
-func (ml *MultiLine) Flush() *router.Message {
-	var buffer []string
-	var msg *router.Message
+// Flush returns the pending group as a single event, or nil if nothing is
+// pending. The pending lines are kept.
+func (ml *MultiLine) Flush() *Event {
+	if ml.PendingSize() == 0 {
+		return nil
+	}
 
+	var buffer []string
 	for _, message := range ml.pending {
 		buffer = append(buffer, message.Data)
 	}
 
-	if ml.PendingSize() > 0 {
-		msg = new(router.Message)
-		*msg = *ml.pending[0]
-		msg.Data = strings.Join(buffer, ml.separator)
+	msg := new(router.Message)
+	*msg = *ml.pending[0]
+	msg.Data = strings.Join(buffer, ml.separator)
+
+	return &Event{
+		Message:   msg,
+		Lines:     ml.totalLines,
+		Bytes:     ml.totalBytes,
+		Truncated: ml.truncated,
+		Rule:      ml.rule,
 	}
+}
 
-	return msg
+func (ml *MultiLine) reset() {
+	ml.pending = nil
+	ml.pendingBytes = 0
+	ml.totalLines = 0
+	ml.totalBytes = 0
+	ml.truncated = false
+	ml.rule = ""
 }
 
 func (ml *MultiLine) PendingSize() int {
 	return len(ml.pending)
 }
 
-func (ml *MultiLine) Expire(t time.Time, ttl time.Duration) *router.Message {
-	if isExpired(t, ml.LastTouched, ttl) {
+// PendingBytes returns the size of the pending lines and their separators.
+func (ml *MultiLine) PendingBytes() int {
+	return ml.pendingBytes
+}
+
+// PendingTime returns the Time of the first pending line, if any.
+func (ml *MultiLine) PendingTime() (time.Time, bool) {
+	if ml.PendingSize() == 0 {
+		return time.Time{}, false
+	}
+	return ml.pending[0].Time, true
+}
+
+// Expire flushes the pending group if it has been idle for longer than ttl,
+// or the buffer's own idle timeout when one is configured, or if it is older
+// than the maximum duration.
+func (ml *MultiLine) Expire(t time.Time, ttl time.Duration) *Event {
+	if ml.idleTimeout > 0 {
+		ttl = ml.idleTimeout
+	}
+	if isExpired(t, ml.LastTouched, ttl) || ml.isTooOld(t) {
 		return ml.Flush()
 	} else {
 		return nil
 	}
 }
 
+// Deadline returns the earliest time at which Expire flushes the pending
+// group, given the same ttl. ok is false when nothing is pending.
+func (ml *MultiLine) Deadline(ttl time.Duration) (deadline time.Time, ok bool) {
+	if ml.PendingSize() == 0 {
+		return time.Time{}, false
+	}
+	if ml.idleTimeout > 0 {
+		ttl = ml.idleTimeout
+	}
+	// isExpired requires strictly more than ttl to have passed
+	deadline = ml.LastTouched.Add(ttl + time.Nanosecond)
+	if ml.maxDuration > 0 {
+		if tooOld := ml.FirstTouched.Add(ml.maxDuration); tooOld.Before(deadline) {
+			deadline = tooOld
+		}
+	}
+	return deadline, true
+}
+
 func isExpired(t time.Time, lastTouched time.Time, ttl time.Duration) bool {
 	return t.Sub(lastTouched) > ttl
 }
 
-func (ml *MultiLine) getLastPendingData() string {
-	return ml.pending[ml.PendingSize()-1].Data
+// isTooOld reports whether the pending group is older than maxDuration at t.
+func (ml *MultiLine) isTooOld(t time.Time) bool {
+	return ml.maxDuration > 0 && ml.PendingSize() > 0 &&
+		t.Sub(ml.FirstTouched) >= ml.maxDuration
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
 
 // matchers
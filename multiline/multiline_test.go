@@ -88,14 +88,142 @@ func TestMultilineNextNegateOK(t *testing.T) {
 	)
 }
 
+func TestMultilineBlockOK(t *testing.T) {
+	testMultilineOK(t,
+		MultilineConfig{
+			GroupWith:    "block",
+			StartPattern: regexp.MustCompile(`^BEGIN`),
+			EndPattern:   regexp.MustCompile(`^END`),
+		},
+		"line1\n",
+		"BEGIN\n  line2.1\nline2.2\nEND\n",
+		"line3\n",
+		"line4\n",
+		"BEGIN\nEND\n",
+	)
+}
+
+func TestMultilineBlockXMLOK(t *testing.T) {
+	testMultilineOK(t,
+		MultilineConfig{
+			GroupWith:    "block",
+			StartPattern: regexp.MustCompile(`^<response>`),
+			EndPattern:   regexp.MustCompile(`</response>$`),
+		},
+		"Sending response:\n",
+		`<response>
+<status>ok</status>
+  <items>
+  </items>
+</response>
+`,
+		"<response></response>\n", // starts and ends on the same line
+		"done\n",
+	)
+}
+
+func TestMultilineBlockSQLOK(t *testing.T) {
+	testMultilineOK(t,
+		MultilineConfig{
+			GroupWith:    "block",
+			StartPattern: regexp.MustCompile(`^(SELECT|INSERT|UPDATE|DELETE)\b`),
+			EndPattern:   regexp.MustCompile(`;\s*$`),
+		},
+		"SELECT id\nFROM users\nWHERE name = 'x';\n",
+		"UPDATE users SET name = 'y';\n",
+		"query finished\n",
+	)
+}
+
+func TestMultilineBlockRequiresPatterns(t *testing.T) {
+	_, err := NewMultiLine(&MultilineConfig{
+		GroupWith:    "block",
+		StartPattern: regexp.MustCompile(`^BEGIN`),
+	})
+	assert.NotNil(t, err)
+}
+
+func TestMultilineBlockMaxLinesExceededOk(t *testing.T) {
+	input := []string{
+		"BEGIN\n  line1.1\n  line1.2\nEND\n",
+		"line2\n",
+	}
+	expected := []string{
+		"BEGIN\n  line1.1",
+		"line2",
+	}
+	ml, _ := NewMultiLine(&MultilineConfig{
+		GroupWith:    "block",
+		StartPattern: regexp.MustCompile(`^BEGIN`),
+		EndPattern:   regexp.MustCompile(`^END`),
+		MaxLines:     2,
+	})
+
+	ml, lines := exercise(ml, input...)
+	checkOutput(t, expected, lines)
+}
+
+func TestMultilineAutoOK(t *testing.T) {
+	testMultilineOK(t,
+		MultilineConfig{GroupWith: "auto"},
+		"starting\n",
+		`javax.servlet.ServletException: Something bad happened
+    at com.example.myproject.OpenSessionInViewFilter.doFilter(OpenSessionInViewFilter.java:60)
+Caused by: com.example.myproject.MyProjectServletException
+    at javax.servlet.http.HttpServlet.service(HttpServlet.java:727)
+    ... 27 more
+`,
+		`Traceback (most recent call last):
+  File "app.py", line 3, in <module>
+    main()
+  File "app.py", line 2, in main
+    raise ValueError("bad")
+ValueError: bad
+`,
+		`panic: boom
+
+goroutine 1 [running]:
+main.main()
+	/app/main.go:4 +0x25
+`,
+		"exit status 2\n",
+		`TypeError: Cannot read properties of undefined
+    at Object.<anonymous> (/app/index.js:1:9)
+    at node:internal/main/run_main_module:22:47
+`,
+		`System.InvalidOperationException: outer
+ ---> System.ArgumentException: inner
+   at App.Program.Main(String[] args) in /src/Program.cs:line 12
+   --- End of inner exception stack trace ---
+`,
+		"  indented but not a stack frame\n",
+	)
+}
+
+func TestMultilineAutoRecordsRule(t *testing.T) {
+	ml, _ := NewMultiLine(&MultilineConfig{GroupWith: "auto"})
+
+	ml, lines := exercise(ml,
+		"Traceback (most recent call last):\n  File \"app.py\", line 3\nKeyError: 'x'\n",
+		"plain line\n",
+		"Exception in thread \"main\" java.lang.Error\n\tat Main.main(Main.java:1)\n",
+	)
+
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, "python", lines[0].Rule)
+	assert.Equal(t, 3, lines[0].Lines)
+	assert.Equal(t, "", lines[1].Rule)
+	assert.Equal(t, "java", lines[2].Rule)
+}
+
 func TestMultilineMaxLinesExceededOk(t *testing.T) {
 	input := []string{
 		"line1\n  line1.1\n  line1.2\n",
 		"line2\n  line2.1\n  line2.2\n",
 	}
 	expected := []string{
-		"line1\n  line1.1\n[Truncated]",
-		"line2\n  line2.1\n[Truncated]",
+		"line1\n  line1.1",
+		"line2\n  line2.1",
 	}
 	ml, _ := NewMultiLine(&MultilineConfig{
 		Pattern:   regexp.MustCompile(`^\s`), // next line is indented by spaces
@@ -105,6 +233,54 @@ func TestMultilineMaxLinesExceededOk(t *testing.T) {
 
 	ml, lines := exercise(ml, input...)
 	checkOutput(t, expected, lines)
+	for _, line := range lines {
+		assert.True(t, line.Truncated)
+		assert.Equal(t, 3, line.Lines)
+		assert.Equal(t, 25, line.Bytes)
+	}
+}
+
+func TestMultilineMaxBytesExceededOk(t *testing.T) {
+	input := []string{
+		"line1\n  line1.1\n  line1.2\n",
+		"line2\n",
+	}
+	expected := []string{
+		"line1\n  line1.1\n  li",
+		"line2",
+	}
+	ml, _ := NewMultiLine(&MultilineConfig{
+		Pattern:   regexp.MustCompile(`^\s`), // next line is indented by spaces
+		GroupWith: "previous",
+		MaxBytes:  20,
+	})
+
+	ml, lines := exercise(ml, input...)
+	checkOutput(t, expected, lines)
+
+	assert.True(t, lines[0].Truncated)
+	assert.Equal(t, 3, lines[0].Lines)
+	assert.Equal(t, 25, lines[0].Bytes)
+	assert.Equal(t, 20, len(lines[0].Data))
+
+	assert.False(t, lines[1].Truncated)
+	assert.Equal(t, 1, lines[1].Lines)
+	assert.Equal(t, 5, lines[1].Bytes)
+}
+
+func TestMultilineMaxBytesSingleLineOk(t *testing.T) {
+	ml, _ := NewMultiLine(&MultilineConfig{
+		Pattern:   regexp.MustCompile(`^\s`),
+		GroupWith: "previous",
+		MaxBytes:  5,
+	})
+
+	ml.Buffer(&router.Message{Data: "ab€€cd"}) // € is 3 bytes
+	msg := ml.Flush()
+
+	assert.Equal(t, "ab€", msg.Data, "lines are not cut inside a UTF-8 sequence")
+	assert.True(t, msg.Truncated)
+	assert.Equal(t, 10, msg.Bytes)
 }
 
 func TestCacheExpireTTL(t *testing.T) {
@@ -131,8 +307,76 @@ func TestCacheExpireTTL(t *testing.T) {
 	assert.Nil(t, msg, "Flush not expected when no messages have expired")
 }
 
+func TestCacheExpireIdleTimeout(t *testing.T) {
+	ml, _ := NewMultiLine(&MultilineConfig{
+		Pattern:     regexp.MustCompile(`^\s`),
+		GroupWith:   "previous",
+		IdleTimeout: 100 * time.Millisecond,
+	})
+
+	t0 := time.Now()
+
+	ml.Buffer(&router.Message{Data: "test"})
+	ml.LastTouched = t0
+	msg := ml.Expire(t0.Add(50*time.Millisecond), time.Hour)
+	assert.Nil(t, msg, "Flush not expected before the buffer idle timeout")
+
+	msg = ml.Expire(t0.Add(200*time.Millisecond), time.Hour)
+	assert.NotNil(t, msg, "Buffer idle timeout overrides the cache TTL")
+}
+
+func TestMultilineDeadline(t *testing.T) {
+	ml, _ := NewMultiLine(&MultilineConfig{
+		Pattern:     regexp.MustCompile(`^\s`),
+		GroupWith:   "previous",
+		MaxDuration: time.Minute,
+	})
+
+	_, ok := ml.Deadline(time.Second)
+	assert.False(t, ok, "No deadline without pending lines")
+
+	t0 := time.Now()
+	ml.Buffer(&router.Message{Data: "test"})
+	ml.LastTouched = t0
+	ml.FirstTouched = t0
+
+	deadline, ok := ml.Deadline(time.Second)
+	assert.True(t, ok)
+	assert.Nil(t, ml.Expire(deadline.Add(-time.Nanosecond), time.Second))
+	assert.NotNil(t, ml.Expire(deadline, time.Second), "Expire flushes at the deadline")
+
+	ml.FirstTouched = t0.Add(-time.Minute + time.Millisecond)
+	deadline, _ = ml.Deadline(time.Second)
+	assert.Equal(t, t0.Add(time.Millisecond), deadline, "Max duration can come first")
+}
+
+func TestMultilineMaxDuration(t *testing.T) {
+	ml, _ := NewMultiLine(&MultilineConfig{
+		Pattern:     regexp.MustCompile(`^\s`),
+		GroupWith:   "previous",
+		MaxDuration: time.Minute,
+	})
+
+	assert.Nil(t, ml.Buffer(&router.Message{Data: "line1"}))
+	assert.Nil(t, ml.Buffer(&router.Message{Data: "  line1.1"}))
+
+	// pretend the group was opened long ago
+	ml.FirstTouched = time.Now().Add(-2 * time.Minute)
+
+	msg := ml.Buffer(&router.Message{Data: "  line1.2"})
+	assert.NotNil(t, msg, "Groups older than max duration are flushed")
+	assert.Equal(t, "line1\n  line1.1", msg.Data)
+
+	msg = ml.Flush()
+	assert.Equal(t, "  line1.2", msg.Data, "The next line starts a new group")
+	assert.Equal(t, 1, msg.Lines)
+
+	ml.FirstTouched = time.Now().Add(-2 * time.Minute)
+	assert.NotNil(t, ml.Expire(time.Now(), time.Hour), "Expire flushes groups older than max duration")
+}
+
 func testMultilineOK(t *testing.T, cfg MultilineConfig, expected ...string) {
-	var lines []*router.Message
+	var lines []*Event
 
 	ml, err := NewMultiLine(&cfg)
 	if err != nil {
@@ -144,8 +388,8 @@ func testMultilineOK(t *testing.T, cfg MultilineConfig, expected ...string) {
 	checkOutput(t, expected, lines)
 }
 
-func exercise(ml MultiLine, logInput ...string) (MultiLine, []*router.Message) {
-	var lines []*router.Message
+func exercise(ml MultiLine, logInput ...string) (MultiLine, []*Event) {
+	var lines []*Event
 
 	for _, line := range createLines(logInput...) {
 		msg := ml.Buffer(line)
@@ -159,7 +403,7 @@ func exercise(ml MultiLine, logInput ...string) (MultiLine, []*router.Message) {
 	return ml, lines
 }
 
-func checkOutput(t *testing.T, expected []string, output []*router.Message) {
+func checkOutput(t *testing.T, expected []string, output []*Event) {
 	assert.Equal(t, len(expected), len(output))
 	for i, expected := range expected {
 		actual := output[i]
@@ -169,7 +413,7 @@ func checkOutput(t *testing.T, expected []string, output []*router.Message) {
 	}
 }
 
-func flushPendingLine(ml MultiLine, lines *[]*router.Message) MultiLine {
+func flushPendingLine(ml MultiLine, lines *[]*Event) MultiLine {
 	if len(ml.pending) > 0 && ml.pending[0].Data != "" {
 		msg := ml.Flush()
 		*lines = append(*lines, msg)
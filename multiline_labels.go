@@ -0,0 +1,99 @@
+package logstash
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/anashaka/logspout-logstash/multiline"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// Container labels overriding the route's multiline options, e.g.
+// logstash.multiline.pattern=^\S
+const multilineLabelPrefix = "logstash.multiline."
+
+// validateMultiline returns an error when the route multiline options do not
+// make a valid buffer, e.g. group_with=block without start and end patterns.
+func (a *LogstashAdapter) validateMultiline() error {
+	if _, err := multiline.NewMultiLine(&a.multilineConfig); err != nil {
+		return fmt.Errorf("logstash: invalid multiline options: %v", err)
+	}
+	return nil
+}
+
+// newContainerBuffer creates a multiline buffer for container, falling back to
+// the route defaults when its label overrides are invalid.
+func (a *LogstashAdapter) newContainerBuffer(container *docker.Container) (multiline.MultiLine, error) {
+	ml, err := multiline.NewMultiLine(a.multilineConfigFor(container))
+	if err != nil {
+		log.Println("logstash: invalid multiline labels on container", container.ID+":", err)
+		a.multilineConfigs[container.ID] = &a.multilineConfig
+		return multiline.NewMultiLine(&a.multilineConfig)
+	}
+	return ml, nil
+}
+
+// multilineConfigFor returns the multiline config of container: the route
+// defaults with any logstash.multiline.* labels applied. Configs are cached by
+// container ID so label patterns are compiled once per container.
+func (a *LogstashAdapter) multilineConfigFor(container *docker.Container) *multiline.MultilineConfig {
+	if config, ok := a.multilineConfigs[container.ID]; ok {
+		return config
+	}
+
+	config := a.multilineConfig
+	labels := containerLabels(container)
+
+	labelPattern(container, "pattern", &config.Pattern)
+	labelPattern(container, "start_pattern", &config.StartPattern)
+	labelPattern(container, "end_pattern", &config.EndPattern)
+	if groupWith, ok := labels[multilineLabelPrefix+"group_with"]; ok {
+		config.GroupWith = groupWith
+	}
+	if negate, err := strconv.ParseBool(labels[multilineLabelPrefix+"negate"]); err == nil {
+		config.Negate = negate
+	}
+	if maxLines, err := strconv.Atoi(labels[multilineLabelPrefix+"max_lines"]); err == nil {
+		config.MaxLines = maxLines
+	}
+	if maxBytes, err := strconv.Atoi(labels[multilineLabelPrefix+"max_bytes"]); err == nil {
+		config.MaxBytes = maxBytes
+	}
+	if idleTimeout, err := time.ParseDuration(labels[multilineLabelPrefix+"idle_timeout"]); err == nil {
+		config.IdleTimeout = idleTimeout
+	}
+	if maxDuration, err := time.ParseDuration(labels[multilineLabelPrefix+"max_duration"]); err == nil {
+		config.MaxDuration = maxDuration
+	}
+	if separator, ok := labels[multilineLabelPrefix+"separator"]; ok {
+		config.Separator = &separator
+	}
+
+	a.multilineConfigs[container.ID] = &config
+	return &config
+}
+
+// labelPattern replaces *pattern with the regexp in the named multiline label
+// of container, if it is set and compiles.
+func labelPattern(container *docker.Container, name string, pattern **regexp.Regexp) {
+	expr, ok := containerLabels(container)[multilineLabelPrefix+name]
+	if !ok {
+		return
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		log.Println("logstash: invalid multiline", name, "on container", container.ID+":", err)
+		return
+	}
+	*pattern = re
+}
+
+func containerLabels(container *docker.Container) map[string]string {
+	if container == nil || container.Config == nil {
+		return nil
+	}
+	return container.Config.Labels
+}
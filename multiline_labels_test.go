@@ -0,0 +1,93 @@
+package logstash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamMultilineLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	container.Config.Labels = map[string]string{
+		"logstash.multiline.pattern":    `\\$`,
+		"logstash.multiline.group_with": "next",
+	}
+	lines := []string{
+		`first \`,
+		`second`,
+	}
+
+	go pump(logstream, &container, [][]string{lines})
+
+	adapter.Stream(logstream)
+	assert.Equal(1, len(*results))
+	data := parseResult(assert, (*results)[0])
+	assert.Equal(strings.Join(lines, "\n"), data["message"])
+}
+
+func TestMultilineLabelsFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	adapter := newLogstashAdapter(new(router.Route), nil)
+
+	container := makeDummyContainer("bad")
+	container.Config.Labels = map[string]string{
+		"logstash.multiline.group_with": "sideways",
+		"logstash.multiline.pattern":    `(`,
+		"logstash.multiline.max_lines":  "5",
+	}
+	_, err := adapter.mkBuffer(&container)
+	assert.Nil(err, "invalid labels fall back to the route defaults")
+	assert.Equal(&adapter.multilineConfig, adapter.multilineConfigFor(&container))
+
+	container = makeDummyContainer("good")
+	container.Config.Labels = map[string]string{
+		"logstash.multiline.pattern":     `^\S`,
+		"logstash.multiline.negate":      "true",
+		"logstash.multiline.max_lines":   "5",
+		"logstash.multiline.end_pattern": `^END`,
+	}
+	config := adapter.multilineConfigFor(&container)
+	assert.Equal(`^END`, config.EndPattern.String())
+	assert.Nil(config.StartPattern)
+	assert.Equal(`^\S`, config.Pattern.String())
+	assert.True(config.Negate)
+	assert.Equal(5, config.MaxLines)
+	assert.Equal("previous", config.GroupWith)
+	assert.True(config == adapter.multilineConfigFor(&container), "configs are cached per container")
+
+	plain := makeDummyContainer("plain")
+	assert.Equal(adapter.multilineConfig, *adapter.multilineConfigFor(&plain))
+}
+
+func TestStreamInvalidRouteMultiline(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"group_with": "block"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+	assert.NotNil(adapter.validateMultiline(), "block grouping needs start and end patterns")
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	lines := []string{"first", "second"}
+
+	go pump(logstream, &container, [][]string{lines})
+
+	adapter.Stream(logstream)
+	assert.Empty(adapter.cache, "no buffer is cached for the stream")
+	assert.Equal(2, len(*results), "lines are shipped one by one")
+	for i, result := range *results {
+		data := parseResult(assert, result)
+		assert.Equal(lines[i], data["message"])
+	}
+}
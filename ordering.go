@@ -0,0 +1,75 @@
+package logstash
+
+import (
+	"sort"
+	"time"
+
+	"github.com/anashaka/logspout-logstash/multiline"
+)
+
+// orderEvents implements ordering=time. Events are held back until no stream
+// of their container has a pending group with an earlier first line, then
+// released sorted by first-line time and numbered per container, so that
+// stdout and stderr output interleaves the way it was written. With final
+// set every held event is released.
+func (a *LogstashAdapter) orderEvents(events []*multiline.Event, final bool) []*multiline.Event {
+	for _, event := range events {
+		id := event.Container.ID
+		a.held[id] = append(a.held[id], event)
+	}
+
+	var ready []*multiline.Event
+	for id, held := range a.held {
+		sort.SliceStable(held, func(i, j int) bool {
+			return held[i].Time.Before(held[j].Time)
+		})
+
+		n := len(held)
+		if watermark, ok := a.oldestPending(id); ok && !final {
+			n = sort.Search(len(held), func(i int) bool {
+				return held[i].Time.After(watermark)
+			})
+		}
+
+		for _, event := range held[:n] {
+			a.sequences[id]++
+			event.Sequence = a.sequences[id]
+		}
+		ready = append(ready, held[:n]...)
+
+		if n == len(held) {
+			delete(a.held, id)
+		} else {
+			a.held[id] = held[n:]
+		}
+	}
+
+	return ready
+}
+
+// oldestPending returns the earliest first-line time of the groups pending in
+// any stream of the container.
+func (a *LogstashAdapter) oldestPending(id string) (time.Time, bool) {
+	var oldest time.Time
+	found := false
+	for source := range a.streams[id] {
+		buf := a.cache[id+source]
+		if buf == nil {
+			continue
+		}
+		if t, ok := buf.PendingTime(); ok && (!found || t.Before(oldest)) {
+			oldest, found = t, true
+		}
+	}
+	return oldest, found
+}
+
+func (a *LogstashAdapter) removeStream(id, source string) {
+	delete(a.streams[id], source)
+	if len(a.streams[id]) == 0 {
+		delete(a.streams, id)
+		if a.removed.has(id) {
+			a.stopped = append(a.stopped, id)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package logstash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anashaka/logspout-logstash/multiline"
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamOrdering(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"ordering": "time"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	t0 := time.Now()
+
+	go func() {
+		for i, line := range []struct {
+			source string
+			data   string
+		}{
+			{"stderr", "Exception"},
+			{"stdout", "a"},
+			{"stderr", "  at frame"},
+			{"stdout", "b"},
+			{"stderr", "next error"},
+		} {
+			logstream <- &router.Message{
+				Container: &container,
+				Source:    line.source,
+				Data:      line.data,
+				Time:      t0.Add(time.Duration(i) * time.Millisecond),
+			}
+		}
+		close(logstream)
+	}()
+
+	adapter.Stream(logstream)
+
+	expected := []string{"Exception\n  at frame", "a", "b", "next error"}
+	assert.Equal(len(expected), len(*results))
+	for i, message := range expected {
+		data := parseResult(assert, (*results)[i])
+		assert.Equal(message, data["message"])
+		assert.Equal(float64(i+1), data["sequence"])
+	}
+}
+
+func TestOrderEventsSeparatesContainers(t *testing.T) {
+	assert := assert.New(t)
+
+	adapter := newLogstashAdapter(new(router.Route), nil)
+	first := makeDummyContainer("first")
+	second := makeDummyContainer("second")
+	t0 := time.Now()
+
+	// a pending group in first holds back its later events only
+	pending := router.Message{Container: &first, Source: "stderr", Data: "pending", Time: t0}
+	adapter.bufferMessage(&pending)
+
+	var events []*multiline.Event
+	for _, msg := range []router.Message{
+		{Container: &first, Source: "stdout", Data: "later", Time: t0.Add(time.Second)},
+		{Container: &second, Source: "stdout", Data: "other", Time: t0.Add(time.Second)},
+		{Container: &first, Source: "stdout", Data: "last", Time: t0.Add(2 * time.Second)},
+		{Container: &second, Source: "stdout", Data: "last", Time: t0.Add(2 * time.Second)},
+	} {
+		msg := msg
+		events = append(events, adapter.bufferMessage(&msg)...)
+	}
+
+	ready := adapter.orderEvents(events, false)
+	assert.Equal(1, len(ready))
+	assert.Equal("other", ready[0].Data)
+
+	ready = adapter.orderEvents(adapter.flushPendingMessages(), true)
+	assert.Equal(4, len(ready))
+
+	sequences := map[string]uint64{}
+	for _, event := range ready {
+		if event.Container.ID == "first" {
+			sequences[event.Data] = event.Sequence
+		}
+	}
+	assert.Equal(map[string]uint64{"pending": 1, "later": 2, "last": 3}, sequences)
+}
@@ -0,0 +1,137 @@
+package logstash
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/logspout/router"
+)
+
+const (
+	defaultPartialMaxBytes = 1024 * 1024
+	defaultPartialTimeout  = time.Second
+)
+
+// partialAssembler joins the chunks Docker splits long lines into back into
+// one message. A chunk of exactly partialSize bytes is taken to be followed by
+// more of the same line, which ends with the first shorter chunk. Since a
+// complete line of exactly that size looks the same, reassembly is only done
+// when partial_size is set, e.g. partial_size=16384 for the json-file and
+// journald drivers, which split lines longer than 16KB.
+type partialAssembler struct {
+	partialSize int
+	maxBytes    int
+	timeout     time.Duration
+	pending     map[string]*partialMessage
+	deadlines   *deadlineQueue
+}
+
+type partialMessage struct {
+	first  *router.Message
+	chunks []string
+	size   int
+}
+
+// newPartialAssembler builds an assembler from the route options, or returns
+// nil when partial_size is not set:
+//
+//	partial_size      chunk size marking a partial message
+//	partial_max_bytes size at which a reassembled message is sent regardless
+//	partial_timeout   how long to wait for the rest of a line
+func newPartialAssembler(options map[string]string) *partialAssembler {
+	partialSize, err := strconv.Atoi(options["partial_size"])
+	if err != nil || partialSize <= 0 {
+		return nil
+	}
+
+	maxBytes, err := strconv.Atoi(options["partial_max_bytes"])
+	if err != nil || maxBytes <= 0 {
+		maxBytes = defaultPartialMaxBytes
+	}
+
+	timeout, err := time.ParseDuration(options["partial_timeout"])
+	if err != nil {
+		timeout = defaultPartialTimeout
+	}
+
+	return &partialAssembler{
+		partialSize: partialSize,
+		maxBytes:    maxBytes,
+		timeout:     timeout,
+		pending:     make(map[string]*partialMessage),
+		deadlines:   newDeadlineQueue(),
+	}
+}
+
+// add returns msg, or the message it completes, once a whole line is
+// available, and nil while more chunks are expected.
+func (p *partialAssembler) add(msg *router.Message) *router.Message {
+	key := msg.Container.ID + msg.Source
+	partial := len(msg.Data) == p.partialSize
+
+	pending, ok := p.pending[key]
+	if !ok {
+		if !partial {
+			return msg
+		}
+		p.pending[key] = &partialMessage{
+			first:  msg,
+			chunks: []string{msg.Data},
+			size:   len(msg.Data),
+		}
+		p.deadlines.set(key, time.Now().Add(p.timeout))
+		return nil
+	}
+
+	pending.chunks = append(pending.chunks, msg.Data)
+	pending.size += len(msg.Data)
+	if partial && pending.size < p.maxBytes {
+		return nil
+	}
+
+	delete(p.pending, key)
+	p.deadlines.remove(key)
+	return pending.message()
+}
+
+// expire returns the messages that have waited longer than the timeout for
+// their remaining chunks.
+func (p *partialAssembler) expire(t time.Time) []*router.Message {
+	var messages []*router.Message
+	for _, key := range p.deadlines.popExpired(t) {
+		messages = append(messages, p.pending[key].message())
+		delete(p.pending, key)
+	}
+	return messages
+}
+
+// flush returns all incomplete messages.
+func (p *partialAssembler) flush() []*router.Message {
+	var messages []*router.Message
+	for key, pending := range p.pending {
+		messages = append(messages, pending.message())
+		delete(p.pending, key)
+		p.deadlines.remove(key)
+	}
+	return messages
+}
+
+// flushContainer returns the incomplete messages of the container id.
+func (p *partialAssembler) flushContainer(id string) []*router.Message {
+	var messages []*router.Message
+	for key, pending := range p.pending {
+		if pending.first.Container.ID == id {
+			messages = append(messages, pending.message())
+			delete(p.pending, key)
+			p.deadlines.remove(key)
+		}
+	}
+	return messages
+}
+
+func (m *partialMessage) message() *router.Message {
+	msg := *m.first
+	msg.Data = strings.Join(m.chunks, "")
+	return &msg
+}
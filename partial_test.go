@@ -0,0 +1,110 @@
+package logstash
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamPartialMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	var r router.Route
+	r.Options = map[string]string{"partial_size": "16"}
+	adapter := newLogstashAdapter(&r, mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	line := `{"message":"a long line split in three"}`
+
+	go pump(logstream, &container, [][]string{
+		{line[:16], line[16:32], line[32:]},
+		{"next line"},
+	})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+
+	data := parseResult(assert, (*results)[0])
+	assert.Equal("a long line split in three", data["message"])
+
+	data = parseResult(assert, (*results)[1])
+	assert.Equal("next line", data["message"])
+}
+
+func TestPartialAssemblerMaxBytes(t *testing.T) {
+	p := newPartialAssembler(map[string]string{
+		"partial_size":      "4",
+		"partial_max_bytes": "8",
+	})
+	container := makeDummyContainer("anid")
+
+	msg := makeDummyMessage(&container, "aaaa")
+	assert.Nil(t, p.add(&msg))
+
+	msg = makeDummyMessage(&container, "bbbb")
+	joined := p.add(&msg)
+
+	assert.NotNil(t, joined, "messages reaching the max size are sent")
+	assert.Equal(t, "aaaabbbb", joined.Data)
+}
+
+func TestPartialAssemblerTimeout(t *testing.T) {
+	p := newPartialAssembler(map[string]string{
+		"partial_size":    "4",
+		"partial_timeout": "1s",
+	})
+	container := makeDummyContainer("anid")
+
+	msg := makeDummyMessage(&container, "aaaa")
+	assert.Nil(t, p.add(&msg))
+
+	assert.Empty(t, p.expire(time.Now()))
+
+	expired := p.expire(time.Now().Add(2 * time.Second))
+	assert.Equal(t, 1, len(expired))
+	assert.Equal(t, "aaaa", expired[0].Data)
+	assert.Empty(t, p.flush())
+}
+
+func TestPartialAssemblerSeparatesStreams(t *testing.T) {
+	p := newPartialAssembler(map[string]string{"partial_size": "4"})
+	first := makeDummyContainer("first")
+	second := makeDummyContainer("second")
+
+	msg := makeDummyMessage(&first, "aaaa")
+	assert.Nil(t, p.add(&msg))
+
+	msg = makeDummyMessage(&second, "zz")
+	assert.Equal(t, "zz", p.add(&msg).Data)
+
+	msg = makeDummyMessage(&first, "bb")
+	assert.Equal(t, "aaaabb", p.add(&msg).Data)
+}
+
+func TestPartialAssemblerDisabled(t *testing.T) {
+	assert.Nil(t, newPartialAssembler(map[string]string{"partial_size": "0"}))
+	assert.Nil(t, newPartialAssembler(map[string]string{}), "reassembly is opt-in")
+}
+
+func TestStreamCompleteLineOfChunkSize(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	long := strings.Repeat("x", 16*1024)
+
+	go pump(logstream, &container, [][]string{{long}, {"next"}})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results), "a complete 16KB line is not joined to the next one")
+	assert.Equal(long, parseResult(assert, (*results)[0])["message"])
+	assert.Equal("next", parseResult(assert, (*results)[1])["message"])
+}
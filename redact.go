@@ -0,0 +1,245 @@
+package logstash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	redactOptionPrefix = "redact."
+	redactionsField    = "redactions"
+)
+
+// detector finds one kind of sensitive value. When group is set only that
+// submatch is redacted, e.g. the token after "Bearer ".
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	group   int
+	valid   func(match string) bool
+}
+
+// builtinDetectors are applied in this order, so that a JWT sent as a bearer
+// token is reported as a JWT.
+var builtinDetectors = []detector{
+	{name: "jwt", pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{name: "bearer", pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`), group: 1},
+	{name: "aws_key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "email", pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)},
+	{name: "card", pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), valid: luhnValid},
+}
+
+// redactionSkipKeys are the sections the adapter adds itself, which are
+// never redacted. The host section is skipped only when the adapter added
+// it, since JSON lines keep a host key of their own.
+var redactionSkipKeys = map[string]bool{
+	"docker":     true,
+	"component":  true,
+	"swarm":      true,
+	"kubernetes": true,
+	"multiline":  true,
+}
+
+// redactor replaces sensitive values in the message and parsed fields of
+// events, configured with the route options:
+//
+//	redact         built-in detectors to apply: jwt, bearer, aws_key, email,
+//	               card (Luhn checked) or all
+//	redact.<name>  a custom detector regexp
+//	redact_mode    replace (default) to write [REDACTED:<name>], or hash to
+//	               write [REDACTED:<name>:<hmac>] so equal values can still be
+//	               correlated
+//	redact_key     HMAC-SHA256 key of redact_mode=hash
+//
+// The number of values redacted in an event is added as a redactions field.
+type redactor struct {
+	detectors []detector
+	key       []byte
+}
+
+// newRedactor returns nil when no detector is configured.
+func newRedactor(options map[string]string) *redactor {
+	r := &redactor{}
+
+	names := splitList(options["redact"])
+	for _, name := range names {
+		if name == "all" {
+			r.detectors = append([]detector{}, builtinDetectors...)
+			names = nil
+			break
+		}
+	}
+	for _, name := range names {
+		found := false
+		for _, builtin := range builtinDetectors {
+			if builtin.name == name {
+				r.detectors = append(r.detectors, builtin)
+				found = true
+			}
+		}
+		if !found {
+			log.Println("logstash: unknown redact detector:", name)
+		}
+	}
+
+	var custom []string
+	for option := range options {
+		if name := strings.TrimPrefix(option, redactOptionPrefix); name != option && name != "" {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	for _, name := range custom {
+		pattern, err := regexp.Compile(options[redactOptionPrefix+name])
+		if err != nil {
+			log.Println("logstash: invalid redact pattern", name+":", err)
+			continue
+		}
+		r.detectors = append(r.detectors, detector{name: name, pattern: pattern})
+	}
+
+	if len(r.detectors) == 0 {
+		return nil
+	}
+
+	switch mode := options["redact_mode"]; mode {
+	case "", "replace":
+	case "hash":
+		if options["redact_key"] == "" {
+			log.Println("logstash: redact_mode=hash needs a redact_key, replacing instead")
+		} else {
+			r.key = []byte(options["redact_key"])
+		}
+	default:
+		log.Println("logstash: unknown redact_mode:", mode)
+	}
+
+	return r
+}
+
+// redactDocument redacts the string and number values of the event document
+// obj, apart from the sections the adapter adds, and records how many were
+// redacted. hostAdded tells whether the host key is the adapter's section.
+func (r *redactor) redactDocument(obj *jsonObject, hostAdded bool) {
+	count := 0
+	for _, key := range obj.Keys() {
+		if redactionSkipKeys[key] || key == "host" && hostAdded {
+			continue
+		}
+		value, _ := obj.Get(key)
+		if redacted, n := r.redactValue(value); n > 0 {
+			obj.Set(key, redacted)
+			count += n
+		}
+	}
+	if count > 0 {
+		obj.Set(redactionsField, count)
+	}
+}
+
+// redactValue returns value with its strings redacted, and the number of
+// values redacted.
+func (r *redactor) redactValue(value interface{}) (interface{}, int) {
+	switch v := value.(type) {
+	case string:
+		return r.redactString(v)
+	case json.Number:
+		// e.g. a card number logged as a number
+		if redacted, n := r.redactString(string(v)); n > 0 {
+			return redacted, n
+		}
+		return v, 0
+	case []interface{}:
+		count := 0
+		for i, item := range v {
+			redacted, n := r.redactValue(item)
+			v[i] = redacted
+			count += n
+		}
+		return v, count
+	case *jsonObject:
+		count := 0
+		for _, key := range v.Keys() {
+			item, _ := v.Get(key)
+			if redacted, n := r.redactValue(item); n > 0 {
+				v.Set(key, redacted)
+				count += n
+			}
+		}
+		return v, count
+	}
+
+	// sections serialize adds as structs, such as javaLog
+	if obj := asJSONObject(value); obj != nil {
+		if redacted, n := r.redactValue(obj); n > 0 {
+			return redacted, n
+		}
+	}
+	return value, 0
+}
+
+func (r *redactor) redactString(s string) (string, int) {
+	count := 0
+	for _, d := range r.detectors {
+		matches := d.pattern.FindAllStringSubmatchIndex(s, -1)
+		if matches == nil {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, match := range matches {
+			start, end := match[2*d.group], match[2*d.group+1]
+			if start < 0 || d.valid != nil && !d.valid(s[start:end]) {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(r.replacement(d.name, s[start:end]))
+			last = end
+			count++
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s, count
+}
+
+func (r *redactor) replacement(name, value string) string {
+	if r.key == nil {
+		return "[REDACTED:" + name + "]"
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return "[REDACTED:" + name + ":" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+}
+
+// luhnValid reports whether the digits of number, ignoring spaces and
+// dashes, pass the Luhn checksum of card numbers.
+func luhnValid(number string) bool {
+	sum := 0
+	digits := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
@@ -0,0 +1,50 @@
+package logstash
+
+import (
+	"github.com/fsouza/go-dockerclient"
+)
+
+// SwarmInfo describes the swarm service task a container runs.
+type SwarmInfo struct {
+	Service   string `json:"service"`
+	ServiceID string `json:"serviceId,omitempty"`
+	TaskID    string `json:"taskId,omitempty"`
+	TaskName  string `json:"taskName,omitempty"`
+	NodeID    string `json:"nodeId,omitempty"`
+	Stack     string `json:"stack,omitempty"`
+}
+
+// swarmInfo returns the swarm section of container, or nil outside of swarm
+// mode.
+func swarmInfo(container *docker.Container) *SwarmInfo {
+	labels := containerLabels(container)
+	service := labels["com.docker.swarm.service.name"]
+	taskID := labels["com.docker.swarm.task.id"]
+	if service == "" && taskID == "" {
+		return nil
+	}
+	return &SwarmInfo{
+		Service:   service,
+		ServiceID: labels["com.docker.swarm.service.id"],
+		TaskID:    taskID,
+		TaskName:  labels["com.docker.swarm.task.name"],
+		NodeID:    labels["com.docker.swarm.node.id"],
+		Stack:     labels["com.docker.stack.namespace"],
+	}
+}
+
+// serviceName names the service container belongs to: its compose service,
+// or else its swarm service or its Kubernetes container name.
+func serviceName(container *docker.Container) string {
+	labels := containerLabels(container)
+	for _, label := range []string{
+		"com.docker.compose.service",
+		"com.docker.swarm.service.name",
+		"io.kubernetes.container.name",
+	} {
+		if name := labels[label]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
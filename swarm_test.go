@@ -0,0 +1,60 @@
+package logstash
+
+import (
+	"testing"
+
+	"github.com/gliderlabs/logspout/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamSwarm(t *testing.T) {
+	assert := assert.New(t)
+
+	mockWriter, results := makeMockWriter()
+	adapter := newLogstashAdapter(new(router.Route), mockWriter)
+
+	logstream := make(chan *router.Message)
+	container := makeDummyContainer("anid")
+	container.Name = "/web_api.1.x8jwkk0j6kq6itu1rdqqnssyf"
+	container.Config.Labels = map[string]string{
+		"com.docker.swarm.service.name": "web_api",
+		"com.docker.swarm.service.id":   "q0clrvz9ecq1",
+		"com.docker.swarm.task.id":      "x8jwkk0j6kq6itu1rdqqnssyf",
+		"com.docker.swarm.task.name":    "web_api.1.x8jwkk0j6kq6itu1rdqqnssyf",
+		"com.docker.swarm.node.id":      "nd2h1m4nfnh3",
+		"com.docker.stack.namespace":    "web",
+	}
+
+	go pump(logstream, &container, [][]string{{"plain"}, {`{"message": "json"}`}})
+
+	adapter.Stream(logstream)
+	assert.Equal(2, len(*results))
+
+	for _, result := range *results {
+		data := parseResult(assert, result)
+		assert.Equal(map[string]interface{}{
+			"service":   "web_api",
+			"serviceId": "q0clrvz9ecq1",
+			"taskId":    "x8jwkk0j6kq6itu1rdqqnssyf",
+			"taskName":  "web_api.1.x8jwkk0j6kq6itu1rdqqnssyf",
+			"nodeId":    "nd2h1m4nfnh3",
+			"stack":     "web",
+		}, data["swarm"])
+		component := data["component"].(map[string]interface{})
+		assert.Equal("web_api", component["name"])
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	assert := assert.New(t)
+
+	container := makeDummyContainer("anid")
+	assert.Equal("", serviceName(&container))
+	assert.Nil(swarmInfo(&container))
+
+	container.Config.Labels = map[string]string{
+		"com.docker.swarm.service.name": "swarm",
+		"com.docker.compose.service":    "compose",
+	}
+	assert.Equal("compose", serviceName(&container), "compose services take precedence")
+}